@@ -0,0 +1,228 @@
+/*
+Copyright © 2022 Harry Law <hrryslw@pm.me>
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const mainUrl string = "https://pastes.ch"
+
+// Client sends requests to a paste-server instance
+type Client struct {
+	baseUrl    string
+	httpClient *http.Client
+	retry      RetryPolicy
+	headers    http.Header
+}
+
+// Option configures optional settings of a Client
+type Option func(*Client)
+
+// WithHTTPClient sets the http.Client used to send requests
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		if httpClient != nil {
+			c.httpClient = httpClient
+		}
+	}
+}
+
+// WithHeader adds a header sent with every request
+func WithHeader(key, value string) Option {
+	return func(c *Client) {
+		c.headers.Add(key, value)
+	}
+}
+
+// WithRetryPolicy sets the policy used to retry failed requests, by default
+// requests are not retried
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *Client) {
+		c.retry = policy
+	}
+}
+
+// NewClient creates a Client for the paste-server at baseUrl, if baseUrl is
+// empty the main hosted server is used
+func NewClient(baseUrl string, opts ...Option) *Client {
+	if baseUrl == "" {
+		baseUrl = mainUrl
+	}
+	c := &Client{
+		baseUrl:    strings.TrimRight(baseUrl, "/"),
+		httpClient: http.DefaultClient,
+		headers:    make(http.Header),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// BaseUrl returns the url of the paste-server the client talks to
+func (c *Client) BaseUrl() string {
+	return c.baseUrl
+}
+
+// do sends a request with an optional JSON body to the path given and returns
+// the body of the response, retrying failed attempts according to the retry
+// policy of the client
+func (c *Client) do(
+	ctx context.Context,
+	method, path string,
+	payload interface{},
+) ([]byte, error) {
+	// Create request JSON body
+	var b []byte
+	if payload != nil {
+		var err error
+		b, err = json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for attempt := 1; ; attempt++ {
+		body, err := c.send(ctx, method, path, b)
+		if err == nil {
+			return body, nil
+		}
+
+		// Give up if the error is permanent or out of attempts
+		if ctx.Err() != nil || attempt >= c.retry.MaxAttempts ||
+			!c.retry.retryable(method, err) {
+			return nil, err
+		}
+
+		// Give up early if the server asks to wait too long or the wait
+		// would outlast the context
+		wait, ok := c.retry.backoff(attempt, err)
+		if !ok {
+			return nil, err
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return nil, err
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, err
+		case <-timer.C:
+		}
+	}
+}
+
+// send makes a single attempt at a request and returns the body of the
+// response, errors building the request are wrapped in a *permanentError
+func (c *Client) send(
+	ctx context.Context,
+	method, path string,
+	payload []byte,
+) ([]byte, error) {
+	req, err := http.NewRequestWithContext(
+		ctx,
+		method,
+		c.baseUrl+path,
+		bytes.NewReader(payload),
+	)
+	if err != nil {
+		return nil, &permanentError{err}
+	}
+	for key, values := range c.headers {
+		req.Header[key] = values
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// Check for errors in request
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		e := newError(req, resp.StatusCode, body)
+		e.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+		return nil, e
+	}
+
+	return body, nil
+}
+
+// PasteUrl returns the url at which the paste with the given uuid is viewed
+func (c *Client) PasteUrl(uuid string) (*url.URL, error) {
+	u, err := url.Parse(c.baseUrl)
+	if err != nil {
+		return nil, err
+	}
+	u.Path = strings.TrimRight(u.Path, "/") + "/" + uuid
+	return u, nil
+}
+
+// Ping checks the paste-server can be reached, any response other than a
+// server error counts as reachable
+func (c *Client) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseUrl, nil)
+	if err != nil {
+		return err
+	}
+	for key, values := range c.headers {
+		req.Header[key] = values
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 500 {
+		return &Error{
+			StatusCode: resp.StatusCode,
+			Method:     req.Method,
+			Path:       "/",
+			Message:    "Server error",
+		}
+	}
+	return nil
+}
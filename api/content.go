@@ -0,0 +1,105 @@
+/*
+Copyright © 2022 Harry Law <hrryslw@pm.me>
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+package api
+
+import (
+	"bytes"
+	"encoding/pem"
+	"errors"
+	"strings"
+	"unicode/utf8"
+)
+
+// binaryBlockType is the PEM block type marking base64 encoded binary content
+const binaryBlockType = "PASTE BINARY"
+
+// reservedBlockPrefix starts the first line of content in a PEM block of one
+// of the types used by paste, such as binary or encrypted content
+const reservedBlockPrefix = "-----BEGIN PASTE "
+
+// SplitLines splits content into the lines sent to the paste-server keeping
+// it byte exact, line endings other than "\n" are kept at the end of each line
+// and a trailing newline results in a final empty line
+func SplitLines(content []byte) []string {
+	return strings.Split(string(content), "\n")
+}
+
+// JoinLines joins lines received from the paste-server back into the content
+// they were split from
+func JoinLines(lines []string) []byte {
+	return []byte(strings.Join(lines, "\n"))
+}
+
+// IsBinary checks if content cannot be sent to the paste-server as text
+func IsBinary(content []byte) bool {
+	return !utf8.Valid(content) || bytes.IndexByte(content, 0) >= 0
+}
+
+// EncodeContent returns the lines sent to the paste-server for content,
+// binary content is base64 encoded inside a PEM block so it survives being
+// stored as text. Text starting with the line of one of the PEM blocks used
+// by paste is encoded the same way so it isn't read back as that block.
+func EncodeContent(content []byte) []string {
+	lines := SplitLines(content)
+	if !IsBinary(content) && !isReserved(lines) {
+		return lines
+	}
+	armored := pem.EncodeToMemory(&pem.Block{
+		Type:  binaryBlockType,
+		Bytes: content,
+	})
+	return SplitLines(bytes.TrimSuffix(armored, []byte("\n")))
+}
+
+// DecodeContent returns the original content of the lines received from the
+// paste-server, decoding binary content
+func DecodeContent(lines []string) ([]byte, error) {
+	if !isBlock(lines, binaryBlockType) {
+		return JoinLines(lines), nil
+	}
+	block, _ := pem.Decode(JoinLines(lines))
+	if block == nil || block.Type != binaryBlockType {
+		return nil, errors.New("Invalid binary paste: malformed encoding")
+	}
+	return block.Bytes, nil
+}
+
+// isReserved checks if the first line of text starts like a PEM block of one
+// of the types used by paste
+func isReserved(lines []string) bool {
+	return strings.HasPrefix(strings.TrimSpace(lines[0]), reservedBlockPrefix)
+}
+
+// isBlock checks if the lines hold a PEM block of the given type
+func isBlock(lines []string, blockType string) bool {
+	return len(lines) > 0 &&
+		strings.TrimSpace(lines[0]) == "-----BEGIN "+blockType+"-----"
+}
@@ -0,0 +1,71 @@
+/*
+Copyright © 2022 Harry Law <hrryslw@pm.me>
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+package api
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestContentRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		armored bool
+	}{
+		{"empty", "", false},
+		{"text", "hello\nworld", false},
+		{"trailing newline", "hello\n", false},
+		{"crlf", "hello\r\nworld\r\n", false},
+		{"binary", "\x00\x01\xff", true},
+		{"invalid utf8", "caf\xe9", true},
+		{"binary marker", "-----BEGIN PASTE BINARY-----\nAAAA\n-----END PASTE BINARY-----\n", true},
+		{"encrypted marker", "-----BEGIN PASTE ENCRYPTED-----\nVersion: 1\n\nAAAA\n-----END PASTE ENCRYPTED-----\n", true},
+		{"indented marker", "  -----BEGIN PASTE ENCRYPTED-----", true},
+		{"other pem block", "-----BEGIN CERTIFICATE-----\nAAAA\n-----END CERTIFICATE-----\n", false},
+		{"marker after first line", "notes\n-----BEGIN PASTE BINARY-----\n", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := EncodeContent([]byte(tt.content))
+			if armored := isBlock(lines, binaryBlockType); armored != tt.armored {
+				t.Errorf("EncodeContent armored = %t, want %t", armored, tt.armored)
+			}
+			got, err := PasteResponse{Content: lines}.Bytes()
+			if err != nil {
+				t.Fatalf("Bytes: %s", err)
+			}
+			if !bytes.Equal(got, []byte(tt.content)) {
+				t.Errorf("Bytes = %q, want %q", got, tt.content)
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+/*
+Copyright © 2022 Harry Law <hrryslw@pm.me>
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+package api
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+)
+
+// encryptedBlockType is the PEM block type marking encrypted content
+const encryptedBlockType = "PASTE ENCRYPTED"
+
+// Versions of the encrypted content format
+const (
+	encryptionVersion = "1"
+	cipherAESGCM      = "AES-256-GCM"
+)
+
+// KeySize is the size in bytes of the keys used to encrypt pastes
+const KeySize = 32
+
+var (
+	// ErrEncrypted is returned reading encrypted content without a key
+	ErrEncrypted = errors.New("paste is encrypted")
+	// ErrDecrypt is returned when encrypted content can't be decrypted
+	ErrDecrypt = errors.New("unable to decrypt paste: wrong key or corrupt content")
+)
+
+// NewKey generates a random key for encrypting a paste
+func NewKey() ([]byte, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// EncodeKey encodes a key for use in the fragment of a paste url
+func EncodeKey(key []byte) string {
+	return base64.RawURLEncoding.EncodeToString(key)
+}
+
+// DecodeKey decodes a key taken from the fragment of a paste url
+func DecodeKey(s string) ([]byte, error) {
+	key, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(key) != KeySize {
+		return nil, errors.New("Invalid key: expected 32 base64url encoded bytes")
+	}
+	return key, nil
+}
+
+// encryptContent encrypts content with AES-256-GCM under key and returns it
+// base64 encoded inside a PEM block, extra headers are stored alongside the
+// block and authenticated with the content
+func encryptContent(
+	content, key []byte,
+	headers map[string]string,
+) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	h := map[string]string{
+		"Version": encryptionVersion,
+		"Cipher":  cipherAESGCM,
+	}
+	for k, v := range headers {
+		h[k] = v
+	}
+	block := &pem.Block{Type: encryptedBlockType, Headers: h}
+	block.Bytes = gcm.Seal(nonce, nonce, content, additionalData(block))
+
+	return pem.EncodeToMemory(block), nil
+}
+
+// decodeEncrypted parses the PEM block of encrypted content and checks its
+// version and cipher are supported
+func decodeEncrypted(lines []string) (*pem.Block, error) {
+	block, _ := pem.Decode(JoinLines(lines))
+	if block == nil || block.Type != encryptedBlockType {
+		return nil, errors.New("Invalid encrypted paste: malformed encoding")
+	}
+	if v := block.Headers["Version"]; v != encryptionVersion {
+		return nil, fmt.Errorf("Unsupported encrypted paste version: %q", v)
+	}
+	if c := block.Headers["Cipher"]; c != cipherAESGCM {
+		return nil, fmt.Errorf("Unsupported encrypted paste cipher: %q", c)
+	}
+	return block, nil
+}
+
+// decryptContent decrypts the content of a block with key
+func decryptContent(block *pem.Block, key []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(block.Bytes) < gcm.NonceSize() {
+		return nil, ErrDecrypt
+	}
+	nonce := block.Bytes[:gcm.NonceSize()]
+	sealed := block.Bytes[gcm.NonceSize():]
+	content, err := gcm.Open(nil, nonce, sealed, additionalData(block))
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return content, nil
+}
+
+// newGCM creates an AES-256-GCM cipher from key
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("Invalid key: expected %d bytes", KeySize)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// additionalData returns the headers of a block in a fixed order so they
+// are authenticated along with the content
+func additionalData(block *pem.Block) []byte {
+	return pem.EncodeToMemory(&pem.Block{
+		Type:    block.Type,
+		Headers: block.Headers,
+	})
+}
@@ -0,0 +1,207 @@
+/*
+Copyright © 2022 Harry Law <hrryslw@pm.me>
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+package api
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// fastScrypt lowers the scrypt parameters of new pastes for the duration of
+// a test
+func fastScrypt(t *testing.T) {
+	t.Helper()
+	params := DefaultScryptParams
+	DefaultScryptParams = ScryptParams{N: 1 << 10, R: 8, P: 1}
+	t.Cleanup(func() { DefaultScryptParams = params })
+}
+
+// mustIdentity generates an identity or fails the test
+func mustIdentity(t *testing.T) *Identity {
+	t.Helper()
+	id, err := GenerateIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+// encryptedPaste returns a paste holding content encrypted with encrypt
+func encryptedPaste(t *testing.T, encrypt func([]byte) ([]byte, error), content []byte) PasteResponse {
+	t.Helper()
+	enc, err := encrypt(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return PasteResponse{Content: SplitLines(enc)}
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	fastScrypt(t)
+	key, err := NewKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice, bob := mustIdentity(t), mustIdentity(t)
+
+	contents := map[string][]byte{
+		"empty":  {},
+		"text":   []byte("hello\nworld\n"),
+		"binary": {0, 1, 2, 0xff},
+		"marker": []byte("-----BEGIN PASTE ENCRYPTED-----\n"),
+	}
+	for name, content := range contents {
+		t.Run(name+"/key", func(t *testing.T) {
+			p := encryptedPaste(t, func(c []byte) ([]byte, error) {
+				return encryptContent(c, key, nil)
+			}, content)
+			if !p.IsEncrypted() || p.IsPasswordProtected() || p.IsEncryptedToRecipients() {
+				t.Fatalf("wrong kind of encrypted paste")
+			}
+			got, err := p.Decrypt(key)
+			if err != nil || !bytes.Equal(got, content) {
+				t.Errorf("Decrypt = %q, %v, want %q", got, err, content)
+			}
+		})
+		t.Run(name+"/password", func(t *testing.T) {
+			p := encryptedPaste(t, func(c []byte) ([]byte, error) {
+				return encryptWithPassword(c, []byte("hunter2"))
+			}, content)
+			if !p.IsPasswordProtected() {
+				t.Fatalf("paste not password protected")
+			}
+			got, err := p.DecryptPassword([]byte("hunter2"))
+			if err != nil || !bytes.Equal(got, content) {
+				t.Errorf("DecryptPassword = %q, %v, want %q", got, err, content)
+			}
+		})
+		t.Run(name+"/recipients", func(t *testing.T) {
+			p := encryptedPaste(t, func(c []byte) ([]byte, error) {
+				return encryptToRecipients(c, []*Recipient{alice.Recipient(), bob.Recipient()})
+			}, content)
+			if !p.IsEncryptedToRecipients() {
+				t.Fatalf("paste not encrypted to recipients")
+			}
+			for _, id := range []*Identity{alice, bob} {
+				got, err := p.DecryptIdentity(id)
+				if err != nil || !bytes.Equal(got, content) {
+					t.Errorf("DecryptIdentity = %q, %v, want %q", got, err, content)
+				}
+			}
+		})
+	}
+}
+
+func TestDecryptFailures(t *testing.T) {
+	fastScrypt(t)
+	key, err := NewKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrongKey, err := NewKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice, eve := mustIdentity(t), mustIdentity(t)
+	content := []byte("secret")
+
+	keyPaste := encryptedPaste(t, func(c []byte) ([]byte, error) {
+		return encryptContent(c, key, nil)
+	}, content)
+	passwordPaste := encryptedPaste(t, func(c []byte) ([]byte, error) {
+		return encryptWithPassword(c, []byte("hunter2"))
+	}, content)
+	recipientsPaste := encryptedPaste(t, func(c []byte) ([]byte, error) {
+		return encryptToRecipients(c, []*Recipient{alice.Recipient()})
+	}, content)
+
+	// Change the cipher text without touching the headers
+	tampered := PasteResponse{Content: append([]string{}, keyPaste.Content...)}
+	for i, line := range tampered.Content {
+		if i > 0 && line != "" && !strings.Contains(line, ":") && !strings.HasPrefix(line, "-----") {
+			b := []byte(line)
+			if b[0] == 'A' {
+				b[0] = 'B'
+			} else {
+				b[0] = 'A'
+			}
+			tampered.Content[i] = string(b)
+			break
+		}
+	}
+
+	tests := []struct {
+		name    string
+		decrypt func() ([]byte, error)
+		want    error
+	}{
+		{"wrong key", func() ([]byte, error) { return keyPaste.Decrypt(wrongKey) }, ErrDecrypt},
+		{"tampered", func() ([]byte, error) { return tampered.Decrypt(key) }, ErrDecrypt},
+		{"no key", func() ([]byte, error) { return keyPaste.Bytes() }, ErrEncrypted},
+		{"wrong password", func() ([]byte, error) { return passwordPaste.DecryptPassword([]byte("hunter3")) }, ErrDecrypt},
+		{"key for password", func() ([]byte, error) { return passwordPaste.Decrypt(key) }, ErrPasswordRequired},
+		{"wrong identity", func() ([]byte, error) { return recipientsPaste.DecryptIdentity(eve) }, ErrNoIdentity},
+		{"no identity", func() ([]byte, error) { return recipientsPaste.DecryptIdentity() }, ErrNoIdentity},
+		{"key for recipients", func() ([]byte, error) { return recipientsPaste.Decrypt(key) }, ErrNoIdentity},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.decrypt()
+			if !errors.Is(err, tt.want) {
+				t.Errorf("got %q, %v, want error %v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncryptWithEmptyPassword(t *testing.T) {
+	if _, err := encryptWithPassword([]byte("secret"), nil); err == nil {
+		t.Error("encryptWithPassword with an empty password succeeded")
+	}
+}
+
+func TestDecodeKey(t *testing.T) {
+	key, err := NewKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := DecodeKey(EncodeKey(key))
+	if err != nil || !bytes.Equal(got, key) {
+		t.Errorf("DecodeKey(EncodeKey(key)) = %x, %v, want %x", got, err, key)
+	}
+	for _, s := range []string{"", "abc", EncodeKey(key[:16]), EncodeKey(key) + "!"} {
+		if _, err := DecodeKey(s); err == nil {
+			t.Errorf("DecodeKey(%q) succeeded", s)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+/*
+Copyright © 2022 Harry Law <hrryslw@pm.me>
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Sentinel errors matched by an *Error using errors.Is
+var (
+	ErrNotFound    = errors.New("paste not found")
+	ErrForbidden   = errors.New("access denied")
+	ErrExpired     = errors.New("paste expired")
+	ErrRateLimited = errors.New("rate limited")
+	ErrInvalid     = errors.New("invalid request")
+	ErrServer      = errors.New("server error")
+)
+
+// Error is returned when the paste-server responds with an error status
+type Error struct {
+	StatusCode int
+	Method     string
+	Path       string
+	Message    string
+	// RetryAfter is the delay requested by the server, if any
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf(
+		"%s (%s %s: %d %s)",
+		e.Message,
+		e.Method,
+		e.Path,
+		e.StatusCode,
+		http.StatusText(e.StatusCode),
+	)
+}
+
+// Is reports whether the error matches one of the sentinel errors
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound && !e.expired()
+	case ErrForbidden:
+		return e.StatusCode == http.StatusUnauthorized ||
+			e.StatusCode == http.StatusForbidden
+	case ErrExpired:
+		return e.StatusCode == http.StatusGone || e.expired()
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrInvalid:
+		return e.StatusCode == http.StatusBadRequest ||
+			e.StatusCode == http.StatusRequestEntityTooLarge ||
+			e.StatusCode == http.StatusUnprocessableEntity
+	case ErrServer:
+		return e.StatusCode >= 500
+	}
+	return false
+}
+
+// expired checks if the server reported a paste not found as it has expired
+func (e *Error) expired() bool {
+	return e.StatusCode == http.StatusNotFound &&
+		strings.Contains(strings.ToLower(e.Message), "expired")
+}
+
+// newError creates an *Error from an error response, parsing the message from
+// either a JSON body or a plain text body
+func newError(req *http.Request, statusCode int, body []byte) *Error {
+	message := strings.TrimSpace(string(body))
+
+	// Prefer the message field of a JSON body if present
+	var m map[string]interface{}
+	if err := json.Unmarshal(body, &m); err == nil {
+		for _, key := range []string{"message", "error"} {
+			if s, ok := m[key].(string); ok && s != "" {
+				message = s
+				break
+			}
+		}
+	}
+	if message == "" {
+		message = http.StatusText(statusCode)
+	}
+
+	return &Error{
+		StatusCode: statusCode,
+		Method:     req.Method,
+		Path:       req.URL.Path,
+		Message:    message,
+	}
+}
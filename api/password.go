@@ -0,0 +1,128 @@
+/*
+Copyright © 2022 Harry Law <hrryslw@pm.me>
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+package api
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// kdfScrypt names the key derivation function used for passwords
+const kdfScrypt = "scrypt"
+
+// saltSize is the size in bytes of the random salt for each password
+const saltSize = 16
+
+// ScryptParams are the cost parameters of the scrypt key derivation function,
+// they are stored with each paste so the defaults can change over time
+type ScryptParams struct {
+	N int
+	R int
+	P int
+}
+
+// DefaultScryptParams are used for newly encrypted pastes
+var DefaultScryptParams = ScryptParams{N: 1 << 15, R: 8, P: 1}
+
+// maxScryptParams limits the cost of decrypting a paste with parameters
+// chosen by whoever created it to 256 MiB of memory (128*N*r), leaving room
+// to raise DefaultScryptParams
+var maxScryptParams = ScryptParams{N: 1 << 18, R: 8, P: 4}
+
+// ErrPasswordRequired is returned decrypting a password protected paste
+// without a password
+var ErrPasswordRequired = errors.New("paste is password protected")
+
+func (p ScryptParams) String() string {
+	return fmt.Sprintf("N=%d,r=%d,p=%d", p.N, p.R, p.P)
+}
+
+// parseScryptParams parses parameters formatted by ScryptParams.String and
+// checks they are within the limits allowed
+func parseScryptParams(s string) (ScryptParams, error) {
+	var p ScryptParams
+	if _, err := fmt.Sscanf(s, "N=%d,r=%d,p=%d", &p.N, &p.R, &p.P); err != nil {
+		return ScryptParams{}, fmt.Errorf("Invalid scrypt parameters: %q", s)
+	}
+	if p.N < 2 || p.N&(p.N-1) != 0 || p.N > maxScryptParams.N ||
+		p.R < 1 || p.R > maxScryptParams.R ||
+		p.P < 1 || p.P > maxScryptParams.P {
+		return ScryptParams{}, fmt.Errorf("Unsupported scrypt parameters: %q", s)
+	}
+	return p, nil
+}
+
+// deriveKey derives a content key from a password
+func deriveKey(password, salt []byte, params ScryptParams) ([]byte, error) {
+	return scrypt.Key(password, salt, params.N, params.R, params.P, KeySize)
+}
+
+// encryptWithPassword encrypts content under a key derived from password
+// storing the salt and parameters used in the block headers
+func encryptWithPassword(content, password []byte) ([]byte, error) {
+	if len(password) == 0 {
+		return nil, errors.New("Password must not be empty")
+	}
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	key, err := deriveKey(password, salt, DefaultScryptParams)
+	if err != nil {
+		return nil, err
+	}
+	return encryptContent(content, key, map[string]string{
+		"KDF":        kdfScrypt,
+		"KDF-Params": DefaultScryptParams.String(),
+		"Salt":       base64.StdEncoding.EncodeToString(salt),
+	})
+}
+
+// passwordKey derives the content key of a block from password
+func passwordKey(block *pem.Block, password []byte) ([]byte, error) {
+	if kdf := block.Headers["KDF"]; kdf != kdfScrypt {
+		return nil, fmt.Errorf("Unsupported key derivation function: %q", kdf)
+	}
+	params, err := parseScryptParams(block.Headers["KDF-Params"])
+	if err != nil {
+		return nil, err
+	}
+	salt, err := base64.StdEncoding.DecodeString(block.Headers["Salt"])
+	if err != nil || len(salt) == 0 {
+		return nil, errors.New("Invalid encrypted paste: malformed salt")
+	}
+	return deriveKey(password, salt, params)
+}
@@ -0,0 +1,105 @@
+/*
+Copyright © 2022 Harry Law <hrryslw@pm.me>
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+package api
+
+import (
+	"encoding/base64"
+	"encoding/pem"
+	"testing"
+)
+
+func TestParseScryptParams(t *testing.T) {
+	tests := []struct {
+		params  string
+		want    ScryptParams
+		wantErr bool
+	}{
+		{params: DefaultScryptParams.String(), want: DefaultScryptParams},
+		{params: maxScryptParams.String(), want: maxScryptParams},
+		{params: "N=2,r=1,p=1", want: ScryptParams{N: 2, R: 1, P: 1}},
+		{params: "N=524288,r=8,p=1", wantErr: true},
+		{params: "N=32768,r=9,p=1", wantErr: true},
+		{params: "N=32768,r=8,p=5", wantErr: true},
+		{params: "N=1048576,r=32,p=16", wantErr: true},
+		{params: "N=1000,r=8,p=1", wantErr: true},
+		{params: "N=1,r=8,p=1", wantErr: true},
+		{params: "N=0,r=8,p=1", wantErr: true},
+		{params: "N=32768,r=0,p=1", wantErr: true},
+		{params: "N=32768,r=8,p=0", wantErr: true},
+		{params: "N=-32768,r=8,p=1", wantErr: true},
+		{params: "", wantErr: true},
+		{params: "N=32768", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.params, func(t *testing.T) {
+			got, err := parseScryptParams(tt.params)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseScryptParams(%q) = %v, want error", tt.params, got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("parseScryptParams(%q) = %v, %v, want %v", tt.params, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestMaxScryptParamsMemory(t *testing.T) {
+	// scrypt needs 128*N*r bytes of memory
+	if mem := 128 * maxScryptParams.N * maxScryptParams.R; mem > 256<<20 {
+		t.Errorf("maxScryptParams need %d bytes, want at most 256 MiB", mem)
+	}
+	if _, err := parseScryptParams(DefaultScryptParams.String()); err != nil {
+		t.Errorf("DefaultScryptParams are over the limits: %s", err)
+	}
+}
+
+func TestPasswordKeyRejectsCostlyParams(t *testing.T) {
+	salt := base64.StdEncoding.EncodeToString(make([]byte, saltSize))
+	tests := []struct {
+		name    string
+		headers map[string]string
+	}{
+		{"too costly", map[string]string{"KDF": kdfScrypt, "KDF-Params": "N=1048576,r=32,p=16", "Salt": salt}},
+		{"unknown kdf", map[string]string{"KDF": "argon2id", "KDF-Params": DefaultScryptParams.String(), "Salt": salt}},
+		{"missing salt", map[string]string{"KDF": kdfScrypt, "KDF-Params": DefaultScryptParams.String()}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			block := &pem.Block{Type: encryptedBlockType, Headers: tt.headers}
+			if _, err := passwordKey(block, []byte("hunter2")); err == nil {
+				t.Error("passwordKey succeeded")
+			}
+		})
+	}
+}
@@ -0,0 +1,236 @@
+/*
+Copyright © 2022 Harry Law <hrryslw@pm.me>
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+package api
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+)
+
+// Prefixes of encoded public and secret keys
+const (
+	recipientPrefix = "paste1"
+	identityPrefix  = "PASTE-SECRET-KEY-"
+)
+
+// keyWrapX25519 names the scheme used to wrap the content key per recipient
+const keyWrapX25519 = "X25519"
+
+// recipientHeader prefixes the headers holding a wrapped key per recipient
+const recipientHeader = "Recipient-"
+
+// hkdfInfo binds keys derived for wrapping to this scheme and version
+const hkdfInfo = "paste-cli X25519 v1"
+
+// ErrNoIdentity is returned when none of the identities given can decrypt a
+// paste encrypted to recipients
+var ErrNoIdentity = errors.New("paste is not encrypted to any identity given")
+
+// Recipient is an X25519 public key a paste can be encrypted to
+type Recipient struct {
+	publicKey []byte
+}
+
+// Identity is an X25519 secret key used to decrypt pastes encrypted to its
+// recipient
+type Identity struct {
+	secretKey []byte
+	publicKey []byte
+}
+
+// GenerateIdentity creates a new random identity
+func GenerateIdentity() (*Identity, error) {
+	secret := make([]byte, curve25519.ScalarSize)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	return newIdentity(secret)
+}
+
+// ParseIdentity parses an identity encoded by Identity.String
+func ParseIdentity(s string) (*Identity, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, identityPrefix) {
+		return nil, errors.New("Invalid identity: missing " + identityPrefix + " prefix")
+	}
+	secret, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(s, identityPrefix))
+	if err != nil || len(secret) != curve25519.ScalarSize {
+		return nil, errors.New("Invalid identity: malformed key")
+	}
+	return newIdentity(secret)
+}
+
+// ParseRecipient parses a recipient encoded by Recipient.String
+func ParseRecipient(s string) (*Recipient, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, recipientPrefix) {
+		return nil, errors.New("Invalid recipient: missing " + recipientPrefix + " prefix")
+	}
+	public, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(s, recipientPrefix))
+	if err != nil || len(public) != curve25519.PointSize {
+		return nil, errors.New("Invalid recipient: malformed key")
+	}
+	return &Recipient{publicKey: public}, nil
+}
+
+func newIdentity(secret []byte) (*Identity, error) {
+	public, err := curve25519.X25519(secret, curve25519.Basepoint)
+	if err != nil {
+		return nil, err
+	}
+	return &Identity{secretKey: secret, publicKey: public}, nil
+}
+
+// Recipient returns the public key of the identity
+func (i *Identity) Recipient() *Recipient {
+	return &Recipient{publicKey: i.publicKey}
+}
+
+func (i *Identity) String() string {
+	return identityPrefix + base64.RawURLEncoding.EncodeToString(i.secretKey)
+}
+
+func (r *Recipient) String() string {
+	return recipientPrefix + base64.RawURLEncoding.EncodeToString(r.publicKey)
+}
+
+// encryptToRecipients encrypts content under a random key which is wrapped
+// for each recipient and stored in the block headers
+func encryptToRecipients(content []byte, recipients []*Recipient) ([]byte, error) {
+	if len(recipients) == 0 {
+		return nil, errors.New("No recipients given")
+	}
+	key, err := NewKey()
+	if err != nil {
+		return nil, err
+	}
+	headers := map[string]string{"Key-Wrap": keyWrapX25519}
+	for n, r := range recipients {
+		stanza, err := r.wrap(key)
+		if err != nil {
+			return nil, err
+		}
+		headers[fmt.Sprintf("%s%d", recipientHeader, n+1)] = stanza
+	}
+	return encryptContent(content, key, headers)
+}
+
+// recipientsKey unwraps the content key of a block with the first identity
+// it was encrypted to
+func recipientsKey(block *pem.Block, identities []*Identity) ([]byte, error) {
+	if wrap := block.Headers["Key-Wrap"]; wrap != keyWrapX25519 {
+		return nil, fmt.Errorf("Unsupported key wrapping: %q", wrap)
+	}
+	for name, stanza := range block.Headers {
+		if !strings.HasPrefix(name, recipientHeader) {
+			continue
+		}
+		for _, id := range identities {
+			if key, err := id.unwrap(stanza); err == nil {
+				return key, nil
+			}
+		}
+	}
+	return nil, ErrNoIdentity
+}
+
+// wrap encrypts key to the recipient using an ephemeral X25519 key, returning
+// the ephemeral public key and wrapped key encoded as a header value
+func (r *Recipient) wrap(key []byte) (string, error) {
+	ephemeral, err := GenerateIdentity()
+	if err != nil {
+		return "", err
+	}
+	shared, err := curve25519.X25519(ephemeral.secretKey, r.publicKey)
+	if err != nil {
+		return "", err
+	}
+	aead, err := wrapCipher(shared, ephemeral.publicKey, r.publicKey)
+	if err != nil {
+		return "", err
+	}
+	// The wrapping key is unique so a zero nonce is safe
+	wrapped := aead.Seal(nil, make([]byte, aead.NonceSize()), key, nil)
+	return base64.StdEncoding.EncodeToString(ephemeral.publicKey) + " " +
+		base64.StdEncoding.EncodeToString(wrapped), nil
+}
+
+// unwrap decrypts a key wrapped to the identity's recipient
+func (i *Identity) unwrap(stanza string) ([]byte, error) {
+	parts := strings.Fields(stanza)
+	if len(parts) != 2 {
+		return nil, errors.New("Invalid recipient stanza")
+	}
+	ephemeral, err := base64.StdEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, err
+	}
+	shared, err := curve25519.X25519(i.secretKey, ephemeral)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := wrapCipher(shared, ephemeral, i.publicKey)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Open(nil, make([]byte, aead.NonceSize()), wrapped, nil)
+}
+
+// wrapCipher derives the cipher wrapping a content key from a shared secret
+// bound to both public keys
+func wrapCipher(shared, ephemeral, recipient []byte) (cipher.AEAD, error) {
+	salt := append(append([]byte{}, ephemeral...), recipient...)
+	key := make([]byte, KeySize)
+	kdf := hkdf.New(sha256.New, shared, salt, []byte(hkdfInfo))
+	if _, err := io.ReadFull(kdf, key); err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
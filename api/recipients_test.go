@@ -0,0 +1,83 @@
+/*
+Copyright © 2022 Harry Law <hrryslw@pm.me>
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+package api
+
+import "testing"
+
+func TestIdentityEncoding(t *testing.T) {
+	id := mustIdentity(t)
+	parsed, err := ParseIdentity(id.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.String() != id.String() || parsed.Recipient().String() != id.Recipient().String() {
+		t.Errorf("ParseIdentity(%s) = %s", id, parsed)
+	}
+	r, err := ParseRecipient(" " + id.Recipient().String() + "\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.String() != id.Recipient().String() {
+		t.Errorf("ParseRecipient(%s) = %s", id.Recipient(), r)
+	}
+}
+
+func TestParseInvalidKeys(t *testing.T) {
+	id := mustIdentity(t)
+	for _, s := range []string{
+		"",
+		"paste1",
+		"paste1!!!",
+		"paste1AAAA",
+		id.String(),
+		"age1qqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqq",
+	} {
+		if _, err := ParseRecipient(s); err == nil {
+			t.Errorf("ParseRecipient(%q) succeeded", s)
+		}
+	}
+	for _, s := range []string{
+		"",
+		identityPrefix,
+		identityPrefix + "AAAA",
+		id.Recipient().String(),
+	} {
+		if _, err := ParseIdentity(s); err == nil {
+			t.Errorf("ParseIdentity(%q) succeeded", s)
+		}
+	}
+}
+
+func TestEncryptToNoRecipients(t *testing.T) {
+	if _, err := encryptToRecipients([]byte("secret"), nil); err == nil {
+		t.Error("encryptToRecipients with no recipients succeeded")
+	}
+}
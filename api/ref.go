@@ -0,0 +1,105 @@
+/*
+Copyright © 2022 Harry Law <hrryslw@pm.me>
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// pasteID matches the characters a paste UUID can hold so a reference can't
+// change the path or query of the URLs requested
+var pasteID = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Ref is a reference to a paste given as a UUID or the URL of a paste
+type Ref struct {
+	// Server is the base url of the paste-server taken from a paste URL,
+	// empty if only a UUID was given
+	Server string
+	UUID   string
+	// Key decrypts the paste, taken from the fragment if present
+	Key []byte
+}
+
+// ParseRef parses a paste UUID or URL such as https://pastes.ch/<uuid>#<key>,
+// the server of a URL is everything before the UUID with any trailing /api
+// removed so links to the API work too
+func ParseRef(ref string) (Ref, error) {
+	ref = strings.TrimSpace(ref)
+	if !strings.Contains(ref, "://") {
+		uuid, fragment, _ := strings.Cut(ref, "#")
+		if !pasteID.MatchString(uuid) {
+			return Ref{}, fmt.Errorf("Invalid paste UUID: %q", ref)
+		}
+		key, err := parseFragment(fragment)
+		if err != nil {
+			return Ref{}, err
+		}
+		return Ref{UUID: uuid, Key: key}, nil
+	}
+
+	u, err := url.Parse(ref)
+	if err != nil {
+		return Ref{}, fmt.Errorf("Invalid paste URL: %w", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return Ref{}, errors.New(
+			"Invalid paste URL, expected http(s)://host[:port]/<uuid>",
+		)
+	}
+	dir, uuid := path.Split(strings.TrimRight(u.Path, "/"))
+	if uuid == "" {
+		return Ref{}, fmt.Errorf("No paste UUID in URL: %s", ref)
+	}
+	if !pasteID.MatchString(uuid) {
+		return Ref{}, fmt.Errorf("Invalid paste UUID in URL: %q", uuid)
+	}
+	key, err := parseFragment(u.Fragment)
+	if err != nil {
+		return Ref{}, err
+	}
+
+	dir = strings.TrimSuffix(strings.TrimRight(dir, "/"), "/api")
+	server := url.URL{Scheme: u.Scheme, Host: u.Host, Path: dir}
+	return Ref{Server: server.String(), UUID: uuid, Key: key}, nil
+}
+
+// parseFragment decodes the key in the fragment of a paste reference
+func parseFragment(fragment string) ([]byte, error) {
+	if fragment == "" {
+		return nil, nil
+	}
+	return DecodeKey(fragment)
+}
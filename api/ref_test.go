@@ -0,0 +1,87 @@
+/*
+Copyright © 2022 Harry Law <hrryslw@pm.me>
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+package api
+
+import "testing"
+
+func TestParseRef(t *testing.T) {
+	tests := []struct {
+		ref     string
+		server  string
+		uuid    string
+		wantErr bool
+	}{
+		{ref: "abc-123", uuid: "abc-123"},
+		{ref: "https://pastes.ch/abc-123", server: "https://pastes.ch", uuid: "abc-123"},
+		{ref: "https://pastes.ch/api/abc-123/", server: "https://pastes.ch", uuid: "abc-123"},
+		{ref: "http://localhost:3000/p/abc", server: "http://localhost:3000/p", uuid: "abc"},
+		{ref: "", wantErr: true},
+		{ref: "/", wantErr: true},
+		{ref: "..", wantErr: true},
+		{ref: "abc?x=1", wantErr: true},
+		{ref: "a/b", wantErr: true},
+		{ref: "https://pastes.ch/a%3Fb", wantErr: true},
+		{ref: "https://pastes.ch/", wantErr: true},
+		{ref: "ftp://pastes.ch/abc", wantErr: true},
+		{ref: "abc#not-a-key", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			r, err := ParseRef(tt.ref)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseRef(%q) = %+v, want error", tt.ref, r)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseRef(%q): %s", tt.ref, err)
+			}
+			if r.Server != tt.server || r.UUID != tt.uuid {
+				t.Errorf("ParseRef(%q) = %q %q, want %q %q", tt.ref, r.Server, r.UUID, tt.server, tt.uuid)
+			}
+		})
+	}
+}
+
+func TestParseRefKey(t *testing.T) {
+	key, err := NewKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := ParseRef("https://pastes.ch/abc#" + EncodeKey(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(r.Key) != string(key) {
+		t.Errorf("key = %x, want %x", r.Key, key)
+	}
+}
@@ -31,22 +31,15 @@ POSSIBILITY OF SUCH DAMAGE.
 package api
 
 import (
-	"bufio"
-	"bytes"
+	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
-	"io/ioutil"
 	"net/http"
-	"os"
+	"net/url"
 	"strings"
-
-	"github.com/h5law/paste-cli/utils"
-	"github.com/spf13/viper"
+	"time"
 )
 
-const mainUrl string = "https://pastes.ch"
-
 type PasteResponse struct {
 	Content   []string `json:"content,omitempty"`
 	FileType  string   `json:"filetype,omitempty"`
@@ -54,234 +47,359 @@ type PasteResponse struct {
 	AccessKey string   `json:"accessKey,omitempty"`
 }
 
-func CreatePaste() (map[string]string, error) {
-	url := getUrl()
+// Bytes returns the content of the paste exactly as it was uploaded,
+// decoding binary content, ErrEncrypted is returned for encrypted pastes
+func (p PasteResponse) Bytes() ([]byte, error) {
+	if p.IsEncrypted() {
+		return nil, ErrEncrypted
+	}
+	return DecodeContent(p.Content)
+}
 
-	filePath := viper.GetString("new-file")
-	fileType := viper.GetString("new-filetype")
-	expiresIn := viper.GetInt("new-expiresIn")
+// IsEncrypted checks if the paste holds encrypted content
+func (p PasteResponse) IsEncrypted() bool {
+	return isBlock(p.Content, encryptedBlockType)
+}
 
-	// Set input file depending to either os.Stdin or file flag
-	var input *os.File
-	if filePath == "" {
-		input = os.Stdin
-	} else {
-		// Check file exists and open it
-		exists, err := utils.FileExists(filePath)
-		if err != nil {
-			return nil, err
-		}
-		if !exists {
-			return nil, fmt.Errorf("File not found: %s", filePath)
-		}
-		input, err = os.Open(filePath)
-		if err != nil {
-			return nil, err
-		}
+// IsPasswordProtected checks if the paste was encrypted with a password
+func (p PasteResponse) IsPasswordProtected() bool {
+	if !p.IsEncrypted() {
+		return false
 	}
+	block, err := decodeEncrypted(p.Content)
+	return err == nil && block.Headers["KDF"] != ""
+}
 
-	// Read lines into slice
-	var content []string
-	scanner := bufio.NewScanner(input)
-	for scanner.Scan() {
-		content = append(content, scanner.Text())
+// IsEncryptedToRecipients checks if the paste was encrypted to recipients
+func (p PasteResponse) IsEncryptedToRecipients() bool {
+	if !p.IsEncrypted() {
+		return false
 	}
-	input.Close()
+	block, err := decodeEncrypted(p.Content)
+	return err == nil && block.Headers["Key-Wrap"] != ""
+}
 
-	// Create request JSON body
-	postBody, err := json.Marshal(map[string]interface{}{
-		"content":   content,
-		"filetype":  fileType,
-		"expiresIn": expiresIn,
-	})
+// Decrypt returns the content of an encrypted paste decrypted with key
+func (p PasteResponse) Decrypt(key []byte) ([]byte, error) {
+	block, err := decodeEncrypted(p.Content)
 	if err != nil {
 		return nil, err
 	}
-	requestBody := bytes.NewBuffer(postBody)
+	if block.Headers["KDF"] != "" {
+		return nil, ErrPasswordRequired
+	}
+	if block.Headers["Key-Wrap"] != "" {
+		return nil, ErrNoIdentity
+	}
+	return decryptContent(block, key)
+}
 
-	// Send post request and read body
-	resp, err := http.Post(url+"/api/new", "application/json; charset=utf-8", requestBody)
+// DecryptIdentity returns the content of a paste encrypted to recipients
+// using the first of the identities given it was encrypted to
+func (p PasteResponse) DecryptIdentity(identities ...*Identity) ([]byte, error) {
+	block, err := decodeEncrypted(p.Content)
 	if err != nil {
 		return nil, err
 	}
-	// Check for errors in request
-	body, err := ioutil.ReadAll(resp.Body)
-	defer resp.Body.Close()
-	if resp.StatusCode >= 400 {
-		return nil, errors.New(strings.TrimSpace(string(body)))
-	}
+	key, err := recipientsKey(block, identities)
 	if err != nil {
 		return nil, err
 	}
+	return decryptContent(block, key)
+}
 
-	// Unmarshal JSON response into map to return
-	m := make(map[string]string)
-	if err := json.Unmarshal(body, &m); err != nil {
+// DecryptPassword returns the content of a paste encrypted with a password
+func (p PasteResponse) DecryptPassword(password []byte) ([]byte, error) {
+	block, err := decodeEncrypted(p.Content)
+	if err != nil {
 		return nil, err
 	}
-
-	// Add url field for access
-	m["url"] = url + "/" + m["uuid"]
-
-	return m, nil
+	key, err := passwordKey(block, password)
+	if err != nil {
+		return nil, err
+	}
+	return decryptContent(block, key)
 }
 
-func GetPaste() (PasteResponse, error) {
-	url := getUrl()
+// encryptLike encrypts the new content of an update the same way as the
+// paste, under the same key, password or recipients, returning the lines to
+// send and the key to put in the url of pastes encrypted under a key. Content
+// of unencrypted pastes is only encrypted if the update asks for it.
+func (p PasteResponse) encryptLike(r UpdateRequest) ([]string, []byte, error) {
+	content := r.Content
+	if !p.IsEncrypted() {
+		var err error
+		switch {
+		case r.Recipients != nil:
+			content, err = encryptToRecipients(content, r.Recipients)
+		case r.Password != nil:
+			content, err = encryptWithPassword(content, r.Password)
+		case r.Key != nil:
+			content, err = encryptContent(content, r.Key, nil)
+			return SplitLines(content), r.Key, err
+		default:
+			return EncodeContent(content), nil, nil
+		}
+		return SplitLines(content), nil, err
+	}
 
-	// Send get request and read body
-	uuid := viper.GetString("get-uuid")
-	url += "/api/" + uuid
-	resp, err := http.Get(url)
+	block, err := decodeEncrypted(p.Content)
 	if err != nil {
-		return PasteResponse{}, err
+		return nil, nil, err
 	}
-	// Check for errors in request
-	body, err := ioutil.ReadAll(resp.Body)
-	defer resp.Body.Close()
-	if resp.StatusCode >= 400 {
-		return PasteResponse{}, errors.New(strings.TrimSpace(string(body)))
+	switch {
+	case block.Headers["KDF"] != "":
+		// Check the password unlocks the paste before using it
+		if r.Password == nil {
+			return nil, nil, ErrPasswordRequired
+		}
+		if _, err := p.DecryptPassword(r.Password); err != nil {
+			return nil, nil, err
+		}
+		content, err = encryptWithPassword(content, r.Password)
+		return SplitLines(content), nil, err
+	case block.Headers["Key-Wrap"] != "":
+		if r.Recipients != nil {
+			content, err = encryptToRecipients(content, r.Recipients)
+			return SplitLines(content), nil, err
+		}
+		// Reuse the content key and its wrapped copies so the paste stays
+		// readable by the same recipients
+		key, err := recipientsKey(block, r.Identities)
+		if err != nil {
+			return nil, nil, err
+		}
+		if _, err := decryptContent(block, key); err != nil {
+			return nil, nil, err
+		}
+		content, err = encryptContent(content, key, block.Headers)
+		return SplitLines(content), nil, err
 	}
-	if err != nil {
-		return PasteResponse{}, err
+	if r.Key == nil {
+		return nil, nil, ErrEncrypted
 	}
-
-	// Unmarshal JSON response into PasteResponse struct
-	var paste PasteResponse
-	if err := json.Unmarshal(body, &paste); err != nil {
-		return PasteResponse{}, err
+	if _, err := decryptContent(block, r.Key); err != nil {
+		return nil, nil, err
 	}
+	content, err = encryptContent(content, r.Key, nil)
+	return SplitLines(content), r.Key, err
+}
 
-	return paste, nil
+// CreateResult is the response of the server to a newly created paste
+type CreateResult struct {
+	UUID      string
+	AccessKey string
+	ExpiresAt time.Time
+	// URL holds the key of encrypted pastes in its fragment
+	URL *url.URL
+	// Key is the key encrypted pastes were encrypted with
+	Key []byte
 }
 
-func UpdatePaste() (map[string]string, error) {
-	url := getUrl()
-
-	filePath := viper.GetString("upd-file")
-	fileType := viper.GetString("upd-filetype")
-	expiresIn := viper.GetInt("upd-expiresIn")
-	accessKey := viper.GetString("upd-accessKey")
-
-	// Set input file depending to either os.Stdin or file flag
-	var input *os.File
-	pipe := utils.IsInputFromPipe()
-	if filePath == "" && pipe {
-		input = os.Stdin
-	} else if filePath != "" && !pipe {
-		// Check file exists and open it
-		exists, err := utils.FileExists(filePath)
+// UpdateResult is the response of the server to an updated paste
+type UpdateResult struct {
+	UUID      string
+	ExpiresAt time.Time
+	URL       *url.URL
+}
+
+// resultResponse is the JSON body returned on creating or updating a paste
+type resultResponse struct {
+	UUID      string `json:"uuid"`
+	AccessKey string `json:"accessKey"`
+	ExpiresAt string `json:"expiresAt"`
+}
+
+// CreateRequest holds the fields used to create a new paste
+type CreateRequest struct {
+	Content   []byte
+	FileType  string
+	ExpiresIn int
+	// Encrypt encrypts the content under a random key before it is sent
+	Encrypt bool
+	// Password encrypts the content under a key derived from it when set
+	Password []byte
+	// Recipients encrypts the content so only their identities can read it
+	Recipients []*Recipient
+}
+
+// UpdateRequest holds the fields used to update an existing paste, empty
+// fields and nil content are left unchanged on the server
+type UpdateRequest struct {
+	UUID      string
+	AccessKey string
+	Content   []byte
+	FileType  string
+	ExpiresIn int
+	// Key, Password or Recipients encrypts the content before it is sent
+	// when set, new content of an encrypted paste must be encrypted the same
+	// way using its key, its password or an identity it was encrypted to
+	Key        []byte
+	Password   []byte
+	Recipients []*Recipient
+	Identities []*Identity
+	// Current is the paste being updated, it is fetched when nil
+	Current *PasteResponse
+}
+
+// DeleteRequest holds the fields used to delete a paste
+type DeleteRequest struct {
+	UUID      string
+	AccessKey string
+}
+
+func (c *Client) CreatePaste(ctx context.Context, r CreateRequest) (CreateResult, error) {
+	// Encrypt content to recipients, under a password or a new key if asked
+	content := r.Content
+	var key []byte
+	var err error
+	if r.Recipients != nil {
+		content, err = encryptToRecipients(content, r.Recipients)
 		if err != nil {
-			return nil, err
+			return CreateResult{}, err
 		}
-		if !exists {
-			return nil, fmt.Errorf("File not found: %s", filePath)
+	} else if r.Password != nil {
+		if content, err = encryptWithPassword(content, r.Password); err != nil {
+			return CreateResult{}, err
 		}
-		input, err = os.Open(filePath)
-		if err != nil {
-			return nil, err
+	} else if r.Encrypt {
+		if key, err = NewKey(); err != nil {
+			return CreateResult{}, err
+		}
+		if content, err = encryptContent(content, key, nil); err != nil {
+			return CreateResult{}, err
 		}
 	}
 
-	// Read lines into slice
-	var content []string
-	scanner := bufio.NewScanner(input)
-	for scanner.Scan() {
-		content = append(content, scanner.Text())
+	// Encrypted content is already a PEM block so is sent as it is
+	lines := EncodeContent(content)
+	if r.Recipients != nil || r.Password != nil || r.Encrypt {
+		lines = SplitLines(content)
 	}
-	input.Close()
 
-	// Create request JSON body
-	mi := make(map[string]interface{})
-	if content != nil {
-		mi["content"] = content
+	// Send post request and read body
+	body, err := c.do(ctx, http.MethodPost, "/api/new", map[string]interface{}{
+		"content":   lines,
+		"filetype":  r.FileType,
+		"expiresIn": r.ExpiresIn,
+	})
+	if err != nil {
+		return CreateResult{}, err
 	}
-	if fileType != "" {
-		mi["filetype"] = fileType
+
+	// Unmarshal JSON response and check required fields are present
+	var res resultResponse
+	if err := json.Unmarshal(body, &res); err != nil {
+		return CreateResult{}, err
 	}
-	if expiresIn != 0 {
-		mi["expiresIn"] = expiresIn
+	if res.AccessKey == "" {
+		return CreateResult{}, fmt.Errorf("Invalid response: missing accessKey")
 	}
-	mi["accessKey"] = accessKey
-	putBody, err := json.Marshal(mi)
+	uuid, expiresAt, err := res.parse()
 	if err != nil {
-		return nil, err
+		return CreateResult{}, err
 	}
-	requestBody := bytes.NewBuffer(putBody)
-
-	// Create http client for request
-	client := &http.Client{}
 
-	// Create put request
-	uuid := viper.GetString("upd-uuid")
-	req, err := http.NewRequest(http.MethodPut, url+"/api/"+uuid, requestBody)
+	// Add url field for access with the key in the fragment
+	u, err := c.PasteUrl(uuid)
 	if err != nil {
-		return nil, err
+		return CreateResult{}, err
 	}
-	req.Header.Set("Content-Type", "application/json; charset=utf-8")
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
+	if key != nil {
+		u.Fragment = EncodeKey(key)
 	}
 
-	// Check for errors in request
-	body, err := ioutil.ReadAll(resp.Body)
-	defer resp.Body.Close()
-	if resp.StatusCode >= 400 {
-		return nil, errors.New(strings.TrimSpace(string(body)))
-	}
+	return CreateResult{
+		UUID:      uuid,
+		AccessKey: res.AccessKey,
+		ExpiresAt: expiresAt,
+		URL:       u,
+		Key:       key,
+	}, nil
+}
+
+func (c *Client) GetPaste(ctx context.Context, uuid string) (PasteResponse, error) {
+	// Send get request and read body
+	body, err := c.do(ctx, http.MethodGet, pastePath(uuid), nil)
 	if err != nil {
-		return nil, err
+		return PasteResponse{}, err
 	}
 
-	// Unmarshal JSON response into map to return
-	m := make(map[string]string)
-	if err := json.Unmarshal(body, &m); err != nil {
-		return nil, err
+	// Unmarshal JSON response into PasteResponse struct
+	var paste PasteResponse
+	if err := json.Unmarshal(body, &paste); err != nil {
+		return PasteResponse{}, err
 	}
 
-	// Add url field for access
-	m["url"] = url + "/" + uuid
-
-	return m, nil
+	return paste, nil
 }
 
-func DeletePaste() (string, error) {
-	url := getUrl()
-
-	accessKey := viper.GetString("del-accessKey")
-
+// UpdatePaste updates a paste, new content of an encrypted paste is encrypted
+// the same way as the paste so it is never replaced with plaintext
+func (c *Client) UpdatePaste(ctx context.Context, r UpdateRequest) (UpdateResult, error) {
 	// Create request JSON body
-	delBody, err := json.Marshal(map[string]string{
-		"accessKey": accessKey,
-	})
-	if err != nil {
-		return "", err
+	mi := make(map[string]interface{})
+	key := r.Key
+	if r.Content != nil {
+		if r.Current == nil {
+			current, err := c.GetPaste(ctx, r.UUID)
+			if err != nil {
+				return UpdateResult{}, err
+			}
+			r.Current = &current
+		}
+		var lines []string
+		var err error
+		if lines, key, err = r.Current.encryptLike(r); err != nil {
+			return UpdateResult{}, err
+		}
+		mi["content"] = lines
 	}
-	requestBody := bytes.NewBuffer(delBody)
-
-	// Create http client for request
-	client := &http.Client{}
+	if r.FileType != "" {
+		mi["filetype"] = r.FileType
+	}
+	if r.ExpiresIn != 0 {
+		mi["expiresIn"] = r.ExpiresIn
+	}
+	mi["accessKey"] = r.AccessKey
 
-	// Create delete request
-	uuid := viper.GetString("del-uuid")
-	url += "/api/" + uuid
-	req, err := http.NewRequest(http.MethodDelete, url, requestBody)
+	// Send put request and read body
+	body, err := c.do(ctx, http.MethodPut, pastePath(r.UUID), mi)
 	if err != nil {
-		return "", err
+		return UpdateResult{}, err
 	}
-	req.Header.Set("Content-Type", "application/json; charset=utf-8")
-	resp, err := client.Do(req)
+
+	// Unmarshal JSON response and check required fields are present
+	var res resultResponse
+	if err := json.Unmarshal(body, &res); err != nil {
+		return UpdateResult{}, err
+	}
+	uuid, expiresAt, err := res.parse()
 	if err != nil {
-		return "", err
+		return UpdateResult{}, err
 	}
 
-	// Check for errors in request
-	body, err := ioutil.ReadAll(resp.Body)
-	defer resp.Body.Close()
-	if resp.StatusCode >= 400 {
-		return "", errors.New(strings.TrimSpace(string(body)))
+	// Add url field for access with the key in the fragment
+	u, err := c.PasteUrl(uuid)
+	if err != nil {
+		return UpdateResult{}, err
 	}
+	if key != nil {
+		u.Fragment = EncodeKey(key)
+	}
+
+	return UpdateResult{
+		UUID:      uuid,
+		ExpiresAt: expiresAt,
+		URL:       u,
+	}, nil
+}
+
+func (c *Client) DeletePaste(ctx context.Context, r DeleteRequest) (string, error) {
+	// Send delete request and read body
+	body, err := c.do(ctx, http.MethodDelete, pastePath(r.UUID), map[string]string{
+		"accessKey": r.AccessKey,
+	})
 	if err != nil {
 		return "", err
 	}
@@ -292,10 +410,72 @@ func DeletePaste() (string, error) {
 	return out, nil
 }
 
-func getUrl() string {
-	url := viper.GetString("url")
-	if url == "" {
-		url = mainUrl
+// pastePath returns the API path of the paste with the uuid given
+func pastePath(uuid string) string {
+	return "/api/" + url.PathEscape(uuid)
+}
+
+// parse checks the uuid and expiry date are present in the response and
+// returns them with the expiry date parsed
+func (r resultResponse) parse() (string, time.Time, error) {
+	if r.UUID == "" {
+		return "", time.Time{}, fmt.Errorf("Invalid response: missing uuid")
 	}
-	return url
+	if r.ExpiresAt == "" {
+		return "", time.Time{}, fmt.Errorf("Invalid response: missing expiresAt")
+	}
+	expiresAt, err := time.Parse(time.RFC3339, r.ExpiresAt)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("Invalid response: expiresAt: %w", err)
+	}
+	return r.UUID, expiresAt, nil
+}
+
+// redacted replaces secrets in the string representation of requests so
+// they aren't exposed in logs or debug output
+func redacted(secret []byte) string {
+	if len(secret) == 0 {
+		return ""
+	}
+	return "[REDACTED]"
+}
+
+func (r CreateRequest) String() string {
+	return fmt.Sprintf(
+		"{Content:[%d bytes] FileType:%s ExpiresIn:%d Encrypt:%t Password:%s Recipients:%d}",
+		len(r.Content),
+		r.FileType,
+		r.ExpiresIn,
+		r.Encrypt,
+		redacted(r.Password),
+		len(r.Recipients),
+	)
+}
+
+func (r CreateRequest) GoString() string { return "api.CreateRequest" + r.String() }
+
+func (r UpdateRequest) String() string {
+	return fmt.Sprintf(
+		"{UUID:%s AccessKey:%s Content:[%d bytes] FileType:%s ExpiresIn:%d Key:%s Password:%s Recipients:%d}",
+		r.UUID,
+		redacted([]byte(r.AccessKey)),
+		len(r.Content),
+		r.FileType,
+		r.ExpiresIn,
+		redacted(r.Key),
+		redacted(r.Password),
+		len(r.Recipients),
+	)
 }
+
+func (r UpdateRequest) GoString() string { return "api.UpdateRequest" + r.String() }
+
+func (r DeleteRequest) String() string {
+	return fmt.Sprintf(
+		"{UUID:%s AccessKey:%s}",
+		r.UUID,
+		redacted([]byte(r.AccessKey)),
+	)
+}
+
+func (r DeleteRequest) GoString() string { return "api.DeleteRequest" + r.String() }
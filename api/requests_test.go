@@ -0,0 +1,134 @@
+/*
+Copyright © 2022 Harry Law <hrryslw@pm.me>
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+package api
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestEncryptLike(t *testing.T) {
+	fastScrypt(t)
+	key, err := NewKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrongKey, err := NewKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice, eve := mustIdentity(t), mustIdentity(t)
+	content := []byte("new content")
+
+	keyPaste := encryptedPaste(t, func(c []byte) ([]byte, error) {
+		return encryptContent(c, key, nil)
+	}, []byte("old"))
+	passwordPaste := encryptedPaste(t, func(c []byte) ([]byte, error) {
+		return encryptWithPassword(c, []byte("hunter2"))
+	}, []byte("old"))
+	recipientsPaste := encryptedPaste(t, func(c []byte) ([]byte, error) {
+		return encryptToRecipients(c, []*Recipient{alice.Recipient()})
+	}, []byte("old"))
+
+	tests := []struct {
+		name    string
+		paste   PasteResponse
+		req     UpdateRequest
+		decrypt func(PasteResponse) ([]byte, error)
+		want    error
+	}{
+		{
+			name:    "plaintext",
+			paste:   PasteResponse{Content: []string{"old"}},
+			req:     UpdateRequest{Content: content},
+			decrypt: PasteResponse.Bytes,
+		},
+		{
+			name:    "key",
+			paste:   keyPaste,
+			req:     UpdateRequest{Content: content, Key: key},
+			decrypt: func(p PasteResponse) ([]byte, error) { return p.Decrypt(key) },
+		},
+		{name: "no key", paste: keyPaste, req: UpdateRequest{Content: content}, want: ErrEncrypted},
+		{name: "wrong key", paste: keyPaste, req: UpdateRequest{Content: content, Key: wrongKey}, want: ErrDecrypt},
+		{
+			name:  "password",
+			paste: passwordPaste,
+			req:   UpdateRequest{Content: content, Password: []byte("hunter2")},
+			decrypt: func(p PasteResponse) ([]byte, error) {
+				return p.DecryptPassword([]byte("hunter2"))
+			},
+		},
+		{name: "no password", paste: passwordPaste, req: UpdateRequest{Content: content}, want: ErrPasswordRequired},
+		{
+			name:  "wrong password",
+			paste: passwordPaste,
+			req:   UpdateRequest{Content: content, Password: []byte("hunter3")},
+			want:  ErrDecrypt,
+		},
+		{
+			name:  "identity",
+			paste: recipientsPaste,
+			req:   UpdateRequest{Content: content, Identities: []*Identity{alice}},
+			decrypt: func(p PasteResponse) ([]byte, error) {
+				return p.DecryptIdentity(alice)
+			},
+		},
+		{
+			name:  "wrong identity",
+			paste: recipientsPaste,
+			req:   UpdateRequest{Content: content, Identities: []*Identity{eve}},
+			want:  ErrNoIdentity,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines, _, err := tt.paste.encryptLike(tt.req)
+			if tt.want != nil {
+				if !errors.Is(err, tt.want) {
+					t.Fatalf("encryptLike error = %v, want %v", err, tt.want)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			updated := PasteResponse{Content: lines}
+			if updated.IsEncrypted() != tt.paste.IsEncrypted() {
+				t.Fatalf("updated paste encrypted = %t, want %t", updated.IsEncrypted(), tt.paste.IsEncrypted())
+			}
+			got, err := tt.decrypt(updated)
+			if err != nil || string(got) != string(content) {
+				t.Errorf("updated content = %q, %v, want %q", got, err, content)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+/*
+Copyright © 2022 Harry Law <hrryslw@pm.me>
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+package api
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Second, MaxBackoff: 10 * time.Second}
+	tests := []struct {
+		name    string
+		attempt int
+		err     error
+		max     time.Duration
+		exact   time.Duration
+		giveUp  bool
+	}{
+		{name: "first", attempt: 1, err: errors.New("network"), max: time.Second},
+		{name: "third", attempt: 3, err: errors.New("network"), max: 4 * time.Second},
+		{name: "capped", attempt: 10, err: errors.New("network"), max: 10 * time.Second},
+		{
+			name:    "retry after",
+			attempt: 1,
+			err:     &Error{StatusCode: http.StatusTooManyRequests, RetryAfter: 5 * time.Second},
+			exact:   5 * time.Second,
+		},
+		{
+			name:    "retry after too long",
+			attempt: 1,
+			err:     &Error{StatusCode: http.StatusServiceUnavailable, RetryAfter: 24 * time.Hour},
+			giveUp:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wait, ok := p.backoff(tt.attempt, tt.err)
+			switch {
+			case tt.giveUp:
+				if ok {
+					t.Errorf("backoff = %s, want to give up", wait)
+				}
+			case !ok:
+				t.Errorf("backoff gave up")
+			case tt.exact > 0 && wait != tt.exact:
+				t.Errorf("backoff = %s, want %s", wait, tt.exact)
+			case tt.exact == 0 && (wait < 0 || wait >= tt.max):
+				t.Errorf("backoff = %s, want less than %s", wait, tt.max)
+			}
+		})
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	p := DefaultRetryPolicy()
+	network := errors.New("connection reset")
+	tests := []struct {
+		method string
+		err    error
+		want   bool
+	}{
+		{http.MethodGet, network, true},
+		{http.MethodPost, network, false},
+		{http.MethodPost, &Error{StatusCode: http.StatusTooManyRequests}, true},
+		{http.MethodPut, &Error{StatusCode: http.StatusServiceUnavailable}, true},
+		{http.MethodPost, &Error{StatusCode: http.StatusServiceUnavailable}, false},
+		{http.MethodGet, &Error{StatusCode: http.StatusNotFound}, false},
+		{http.MethodGet, &Error{StatusCode: http.StatusInternalServerError}, false},
+		{http.MethodGet, &permanentError{network}, false},
+	}
+	for _, tt := range tests {
+		if got := p.retryable(tt.method, tt.err); got != tt.want {
+			t.Errorf("retryable(%s, %v) = %t, want %t", tt.method, tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := map[string]time.Duration{
+		"":                              0,
+		"0":                             0,
+		"-1":                            0,
+		"abc":                           0,
+		"120":                           2 * time.Minute,
+		"Mon, 02 Jan 2006 15:04:05 GMT": 0,
+	}
+	for header, want := range tests {
+		if got := parseRetryAfter(header); got != want {
+			t.Errorf("parseRetryAfter(%q) = %s, want %s", header, got, want)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+/*
+Copyright © 2022 Harry Law <hrryslw@pm.me>
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/h5law/paste-cli/api"
+	"github.com/h5law/paste-cli/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// accessKeyEnv is the environment variable an access key can be read from
+const accessKeyEnv = "PASTE_ACCESS_KEY"
+
+// addAccessKeyFlags adds the flags an access key can be given with to cmd,
+// binding them to viper keys starting with prefix
+func addAccessKeyFlags(cmd *cobra.Command, prefix, action string) {
+	cmd.Flags().StringP(
+		"access-key",
+		"a",
+		"",
+		"Access key needed to "+action+" paste (visible to other users, prefer the alternatives)",
+	)
+	cmd.Flags().String(
+		"access-key-file",
+		"",
+		"Read the access key from the first line of a file",
+	)
+	cmd.Flags().Bool(
+		"access-key-stdin",
+		false,
+		"Read the access key from the first line of stdin",
+	)
+	cmd.MarkFlagsMutuallyExclusive("access-key", "access-key-file", "access-key-stdin")
+
+	viper.BindPFlag(prefix+"-accessKey", cmd.Flags().Lookup("access-key"))
+	viper.BindPFlag(prefix+"-accessKeyFile", cmd.Flags().Lookup("access-key-file"))
+	viper.BindPFlag(prefix+"-accessKeyStdin", cmd.Flags().Lookup("access-key-stdin"))
+	viper.SetDefault(prefix+"-accessKey", "")
+	viper.SetDefault(prefix+"-accessKeyFile", "")
+	viper.SetDefault(prefix+"-accessKeyStdin", false)
+}
+
+// lookupAccessKey returns the access key for the paste with the uuid given
+// read from the flags bound to viper keys starting with prefix, the
+// PASTE_ACCESS_KEY environment variable, the keystore, the ledger or a prompt
+// on the terminal in that order
+func lookupAccessKey(client *api.Client, uuid, prefix string) (string, error) {
+	if accessKey := viper.GetString(prefix + "-accessKey"); accessKey != "" {
+		return accessKey, nil
+	}
+	if path := viper.GetString(prefix + "-accessKeyFile"); path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+		return readAccessKey(f, path)
+	}
+	if viper.GetBool(prefix + "-accessKeyStdin") {
+		return readAccessKey(os.Stdin, "stdin")
+	}
+	if accessKey := os.Getenv(accessKeyEnv); accessKey != "" {
+		return accessKey, nil
+	}
+
+	// Look for a stored access key
+	ks, err := openKeystore()
+	if err != nil {
+		return "", err
+	}
+	if ks != nil {
+		accessKey, ok, err := ks.Get(client.BaseUrl(), uuid)
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			return accessKey, nil
+		}
+	}
+	l, err := openLedger()
+	if err != nil {
+		return "", err
+	}
+	entry, ok, err := l.Find(client.BaseUrl(), uuid)
+	if err != nil {
+		return "", err
+	}
+	if ok && entry.AccessKey != "" {
+		return entry.AccessKey, nil
+	}
+
+	// Prompt if stdin is a terminal
+	if !utils.IsInputFromPipe() {
+		accessKey, err := utils.PromptSecret("Access key: ")
+		if err != nil {
+			return "", err
+		}
+		if len(accessKey) > 0 {
+			return string(accessKey), nil
+		}
+	}
+	return "", &codedError{fmt.Errorf(
+		"No access key given and none stored for paste %s, use --access-key-file, --access-key-stdin or %s",
+		uuid,
+		accessKeyEnv,
+	), exitForbidden}
+}
+
+// readAccessKey reads an access key from the first line of r
+func readAccessKey(r io.Reader, name string) (string, error) {
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("Error reading access key from %s: %w", name, err)
+	}
+	accessKey := strings.TrimSpace(line)
+	if accessKey == "" {
+		return "", invalidError(fmt.Errorf("No access key found in %s", name))
+	}
+	return accessKey, nil
+}
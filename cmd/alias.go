@@ -0,0 +1,146 @@
+/*
+Copyright © 2022 Harry Law <hrryslw@pm.me>
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+// aliasCmd represents the alias command
+var (
+	aliasCmd = &cobra.Command{
+		Use:   "alias",
+		Short: "Manage names of pastes",
+		Long: `Manage the names given to pastes in the local ledger, a named paste can be
+given to the get, update and delete commands as @name. The most recent paste
+is also @last and the ones before it @~1, @~2 and so on.`,
+	}
+
+	aliasSetCmd = &cobra.Command{
+		Use:   "set <name> <uuid|url|@ref>",
+		Short: "Name a paste",
+		Long: `Name a paste recorded in the local ledger, the name is taken from any other
+paste that has it.`,
+		Args: cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			name := args[0]
+			if err := checkAliasName(name); err != nil {
+				exitWithError(err)
+			}
+			ref := parseRef(args[1])
+			if ref.Server == "" {
+				ref.Server = newClient().BaseUrl()
+			}
+
+			l, err := openLedger()
+			if err != nil {
+				exitWithError(err)
+			}
+			found, err := l.SetName(ref.Server, ref.UUID, name)
+			if err != nil {
+				exitWithError(err)
+			}
+			if !found {
+				exitWithError(invalidError(fmt.Errorf(
+					"Paste %s on %s is not in the local ledger",
+					ref.UUID,
+					ref.Server,
+				)))
+			}
+		},
+	}
+
+	aliasListCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List named pastes",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			l, err := openLedger()
+			if err != nil {
+				exitWithError(err)
+			}
+			entries, err := l.Entries()
+			if err != nil {
+				exitWithError(err)
+			}
+			results := []aliasResult{}
+			for _, e := range entries {
+				if e.Name != "" {
+					results = append(results, aliasResult{
+						Name:   e.Name,
+						UUID:   e.UUID,
+						Server: e.Server,
+					})
+				}
+			}
+			err = printResult(results, func(out io.Writer) error {
+				w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+				for _, r := range results {
+					fmt.Fprintf(w, "%s\t%s\t%s\n", r.Name, r.UUID, r.Server)
+				}
+				return w.Flush()
+			})
+			if err != nil {
+				exitWithError(err)
+			}
+		},
+	}
+
+	aliasRemoveCmd = &cobra.Command{
+		Use:   "remove <name>",
+		Short: "Remove the name of a paste",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			l, err := openLedger()
+			if err != nil {
+				exitWithError(err)
+			}
+			found, err := l.RemoveName(args[0])
+			if err != nil {
+				exitWithError(err)
+			}
+			if !found {
+				exitWithError(invalidError(fmt.Errorf("Unknown paste name: %s", args[0])))
+			}
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(aliasCmd)
+	aliasCmd.AddCommand(aliasSetCmd)
+	aliasCmd.AddCommand(aliasListCmd)
+	aliasCmd.AddCommand(aliasRemoveCmd)
+}
@@ -0,0 +1,738 @@
+/*
+Copyright © 2022 Harry Law <hrryslw@pm.me>
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/h5law/paste-cli/api"
+	"github.com/h5law/paste-cli/highlight"
+	"github.com/h5law/paste-cli/redact"
+	"github.com/h5law/paste-cli/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// configKind is the kind of value a config key holds
+type configKind int
+
+const (
+	kindString configKind = iota
+	kindInt
+	kindBool
+	kindDuration
+	kindURL
+	kindServer
+	kindGlob
+	kindTheme
+	kindOutput
+)
+
+// configKeys are the config keys known and the kind of value each holds, the
+// servers section is checked separately as its keys are chosen by the user
+var configKeys = map[string]configKind{
+	"url":                   kindURL,
+	"server":                kindServer,
+	"default-server":        kindServer,
+	"timeout":               kindDuration,
+	"retry.max-attempts":    kindInt,
+	"retry.initial-backoff": kindDuration,
+	"retry.max-backoff":     kindDuration,
+	"retry.create":          kindBool,
+	"ledger-file":           kindString,
+	"identity-file":         kindString,
+	"recipients-file":       kindString,
+	"keystore.file":         kindString,
+	"keystore.ttl":          kindDuration,
+	"theme":                 kindTheme,
+	"pager":                 kindString,
+	"output":                kindOutput,
+	"quiet":                 kindBool,
+}
+
+// serverKeys are the keys known for each server in the servers section,
+// headers are checked separately
+var serverKeys = map[string]configKind{
+	"url":                      kindURL,
+	"filetype":                 kindString,
+	"expires":                  kindInt,
+	"tls.ca-file":              kindString,
+	"tls.cert-file":            kindString,
+	"tls.key-file":             kindString,
+	"tls.insecure-skip-verify": kindBool,
+}
+
+// flagKeyPrefixes are the prefixes of the keys command flags are bound to,
+// these can be set in the config to change the default of a flag
+var flagKeyPrefixes = []string{"new-", "get-", "upd-", "del-", "list-"}
+
+// configFlags maps config keys to the persistent flags that set them
+var configFlags = map[string]string{
+	"timeout": "timeout",
+	"server":  "server",
+	"output":  "output",
+	"quiet":   "quiet",
+}
+
+// configCmd represents the config command
+var (
+	configShowOrigin  bool
+	configShowSecrets bool
+
+	configCmd = &cobra.Command{
+		Use:   "config",
+		Short: "Manage the config file",
+		Long: `Create, view, change and validate the config file.
+
+Keys are given in dotted form, for example retry.max-attempts or
+servers.work.url.`,
+	}
+
+	configInitCmd = &cobra.Command{
+		Use:   "init",
+		Short: "Create or update the config file interactively",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			doc := loadConfig()
+
+			def := viper.GetString("url")
+			if def == "" {
+				def = api.NewClient("").BaseUrl()
+			}
+			rawUrl, err := utils.Prompt("Paste-server URL", def)
+			if err != nil {
+				exitWithError(err)
+			}
+			if err := checkServerUrl(rawUrl); err != nil {
+				exitWithError(err)
+			}
+
+			// Check the server can be reached before saving it
+			ctx, cancel := context.WithTimeout(cmd.Context(), 10*time.Second)
+			defer cancel()
+			if err := api.NewClient(rawUrl).Ping(ctx); err != nil {
+				fmt.Fprintf(os.Stderr, "Unable to reach %s: %s\n", rawUrl, err)
+				save, err := utils.Prompt("Save it anyway? (y/n)", "n")
+				if err != nil {
+					exitWithError(err)
+				}
+				if !strings.HasPrefix(strings.ToLower(save), "y") {
+					exitWithError(errors.New("Config not saved"))
+				}
+			}
+
+			t, err := utils.Prompt("Request timeout", viper.GetDuration("timeout").String())
+			if err != nil {
+				exitWithError(err)
+			}
+			if _, err := time.ParseDuration(t); err != nil {
+				exitWithError(invalidError(fmt.Errorf("Invalid timeout: %w", err)))
+			}
+
+			if err := doc.set(rawUrl, "url"); err != nil {
+				exitWithError(err)
+			}
+			if err := doc.set(t, "timeout"); err != nil {
+				exitWithError(err)
+			}
+			if err := doc.save(); err != nil {
+				exitWithError(err)
+			}
+			notef("Config written to %s", doc.path)
+		},
+	}
+
+	configGetCmd = &cobra.Command{
+		Use:   "get <key>",
+		Short: "Print the value of a config key",
+		Long: `Print the value of a config key, the values of server headers are
+replaced by [REDACTED] unless --show-secrets is given.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if !viper.IsSet(args[0]) {
+				exitWithError(fmt.Errorf("Config key not set: %s", args[0]))
+			}
+			v := viper.Get(args[0])
+			if !configShowSecrets {
+				v = redactConfigValue(args[0], v)
+			}
+			err := printResult(v, func(w io.Writer) error {
+				switch v.(type) {
+				case map[string]interface{}, []interface{}:
+					b, err := yaml.Marshal(v)
+					if err != nil {
+						return err
+					}
+					_, err = w.Write(b)
+					return err
+				}
+				_, err := fmt.Fprintln(w, formatConfigValue(v))
+				return err
+			})
+			if err != nil {
+				exitWithError(err)
+			}
+		},
+	}
+
+	configSetCmd = &cobra.Command{
+		Use:   "set <key> <value>",
+		Short: "Set a config key in the config file",
+		Long: `Set a config key in the config file. Flag defaults such as
+new-expiresIn and the rules list take the value as it would be written in the
+file, for example '[{match: "*.go", filetype: go}]' for rules.`,
+		Args: cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			key, raw := args[0], args[1]
+			kind, ok := configKeyKind(key)
+			if !ok && key != "rules" && !isFlagKey(key) {
+				exitWithError(invalidError(fmt.Errorf("Unknown config key: %s", key)))
+			}
+			var value interface{} = raw
+			switch {
+			case !ok:
+				// Flag defaults and rules are parsed as YAML as their type
+				// isn't known here
+				if err := yaml.Unmarshal([]byte(raw), &value); err != nil {
+					exitWithError(invalidError(fmt.Errorf("Invalid value for %s: %w", key, err)))
+				}
+			case kind == kindInt:
+				n, err := strconv.Atoi(raw)
+				if err != nil {
+					exitWithError(invalidError(fmt.Errorf("%s must be a number", key)))
+				}
+				value = n
+			case kind == kindBool:
+				b, err := strconv.ParseBool(raw)
+				if err != nil {
+					exitWithError(invalidError(fmt.Errorf("%s must be true or false", key)))
+				}
+				value = b
+			}
+
+			doc := loadConfig()
+			if err := doc.set(value, strings.Split(key, ".")...); err != nil {
+				exitWithError(err)
+			}
+			for _, p := range validateConfig(doc) {
+				if !p.warning && (p.key == key || strings.HasPrefix(p.key, key+"[")) {
+					exitWithError(invalidError(errors.New(p.message)))
+				}
+			}
+			if err := doc.save(); err != nil {
+				exitWithError(err)
+			}
+		},
+	}
+
+	configUnsetCmd = &cobra.Command{
+		Use:   "unset <key>",
+		Short: "Remove a config key from the config file",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			doc := loadConfig()
+			if !doc.unset(strings.Split(args[0], ".")...) {
+				exitWithError(fmt.Errorf(
+					"Config key not set in %s: %s",
+					doc.path,
+					args[0],
+				))
+			}
+			if err := doc.save(); err != nil {
+				exitWithError(err)
+			}
+		},
+	}
+
+	configViewCmd = &cobra.Command{
+		Use:   "view",
+		Short: "Print the config in effect",
+		Long: `Print every config key set and its value in effect, taking into
+account flags, environment variables, the config files and defaults. The
+values of server headers are replaced by [REDACTED] unless --show-secrets is
+given.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			docs := configDocs
+
+			// Show the keys known along with any others in the files
+			seen := make(map[string]bool)
+			var keys []string
+			for key := range configKeys {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+			for _, doc := range docs {
+				for _, key := range doc.leaves() {
+					if !seen[key] {
+						seen[key] = true
+						keys = append(keys, key)
+					}
+				}
+			}
+			sort.Strings(keys)
+
+			results := []configResult{}
+			for _, key := range keys {
+				v := viper.Get(key)
+				if !configShowSecrets {
+					v = redactConfigValue(key, v)
+				}
+				value := formatConfigValue(v)
+				if value == "" {
+					continue
+				}
+				results = append(results, configResult{
+					Key:    key,
+					Value:  value,
+					Origin: configOrigin(key, docs),
+				})
+			}
+			err := printResult(results, func(out io.Writer) error {
+				w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+				for _, r := range results {
+					if configShowOrigin {
+						fmt.Fprintf(w, "%s\t", r.Origin)
+					}
+					fmt.Fprintf(w, "%s=%s\n", r.Key, r.Value)
+				}
+				return w.Flush()
+			})
+			if err != nil {
+				exitWithError(err)
+			}
+		},
+	}
+
+	configValidateCmd = &cobra.Command{
+		Use:   "validate",
+		Short: "Check the config files for errors",
+		Long: `Check the config files for errors, each problem found is printed to
+stderr and whether each file is valid to stdout.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			layers, err := findConfigLayers()
+			if err != nil {
+				exitWithError(err)
+			}
+			if len(layers) == 0 {
+				notef("No config files found")
+			}
+
+			errs := 0
+			results := []validateResult{}
+			for _, layer := range layers {
+				doc, err := loadConfigDoc(layer.path)
+				if err != nil {
+					fmt.Fprintln(os.Stderr, err)
+					results = append(results, validateResult{File: layer.path, Errors: 1})
+					errs++
+					continue
+				}
+				problems := validateConfig(doc)
+				if layer.name == "project" {
+					problems = append(problems, projectConfigProblems(doc)...)
+					sort.SliceStable(problems, func(i, j int) bool {
+						return problems[i].line < problems[j].line
+					})
+				}
+				result := validateResult{File: doc.path}
+				for _, p := range problems {
+					fmt.Fprintf(os.Stderr, "%s:%s\n", doc.path, p)
+					if p.warning {
+						result.Warnings++
+					} else {
+						result.Errors++
+					}
+				}
+				result.Valid = result.Errors == 0
+				results = append(results, result)
+				errs += result.Errors
+			}
+
+			err = printResult(results, func(out io.Writer) error {
+				for _, r := range results {
+					if r.Valid {
+						fmt.Fprintf(out, "%s is valid\n", r.File)
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				exitWithError(err)
+			}
+			switch {
+			case errs == 1:
+				exitWithError(invalidError(errors.New("1 error in config")))
+			case errs > 1:
+				exitWithError(invalidError(fmt.Errorf("%d errors in config", errs)))
+			}
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configInitCmd)
+	configCmd.AddCommand(configGetCmd)
+	configCmd.AddCommand(configSetCmd)
+	configCmd.AddCommand(configUnsetCmd)
+	configCmd.AddCommand(configViewCmd)
+	configCmd.AddCommand(configValidateCmd)
+
+	configViewCmd.Flags().BoolVar(
+		&configShowOrigin,
+		"show-origin",
+		false,
+		"Show the flag, environment variable, file or default each value is from",
+	)
+	for _, c := range []*cobra.Command{configGetCmd, configViewCmd} {
+		c.Flags().BoolVar(
+			&configShowSecrets,
+			"show-secrets",
+			false,
+			"Show the values of server headers instead of redacting them",
+		)
+	}
+}
+
+// loadConfig loads the config file to edit or inspect, exiting on error
+func loadConfig() *configDoc {
+	path, err := configFilePath()
+	if err != nil {
+		exitWithError(err)
+	}
+	doc, err := loadConfigDoc(path)
+	if err != nil {
+		exitWithError(err)
+	}
+	return doc
+}
+
+// configKeyKind returns the kind of value of a known config key
+func configKeyKind(key string) (configKind, bool) {
+	if kind, ok := configKeys[key]; ok {
+		return kind, true
+	}
+	parts := strings.SplitN(key, ".", 3)
+	if len(parts) < 3 || parts[0] != "servers" {
+		return 0, false
+	}
+	if strings.HasPrefix(parts[2], "headers.") {
+		return kindString, true
+	}
+	kind, ok := serverKeys[parts[2]]
+	return kind, ok
+}
+
+// configOrigin describes where the value in effect for a key comes from
+// given the config files in the order they were merged
+func configOrigin(key string, docs []*configDoc) string {
+	if flag, ok := configFlags[key]; ok && rootCmd.PersistentFlags().Changed(flag) {
+		return "flag:--" + flag
+	}
+	env := configEnv(key)
+	if _, ok := os.LookupEnv(env); ok {
+		return "env:" + env
+	}
+	for i := len(docs) - 1; i >= 0; i-- {
+		if _, ok := docs[i].get(strings.Split(key, ".")...); ok {
+			return "file:" + docs[i].path
+		}
+	}
+	return "default"
+}
+
+// configEnv returns the environment variable setting a config key
+func configEnv(key string) string {
+	return "PASTE_" + envKeyReplacer.Replace(strings.ToUpper(key))
+}
+
+// formatConfigValue formats a config value on one line
+func formatConfigValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case []string:
+		return strings.Join(v, ",")
+	case []interface{}, map[string]interface{}:
+		// Lists and mappings are written as YAML flow collections
+		var n yaml.Node
+		if err := n.Encode(v); err != nil {
+			return fmt.Sprint(v)
+		}
+		n.Style = yaml.FlowStyle
+		b, err := yaml.Marshal(&n)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return strings.TrimSpace(string(b))
+	}
+	return fmt.Sprint(v)
+}
+
+// isHeaderKey reports whether key is a header of a server in the servers
+// section, these often hold secrets such as tokens
+func isHeaderKey(key string) bool {
+	parts := strings.SplitN(key, ".", 4)
+	return len(parts) == 4 &&
+		strings.EqualFold(parts[0], "servers") &&
+		strings.EqualFold(parts[2], "headers")
+}
+
+// redactConfigValue returns the value v of key with the values of any server
+// headers in it replaced by redact.Placeholder
+func redactConfigValue(key string, v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	if isHeaderKey(key) {
+		return redact.Placeholder
+	}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return v
+	}
+	redacted := make(map[string]interface{}, len(m))
+	for k, value := range m {
+		redacted[k] = redactConfigValue(key+"."+k, value)
+	}
+	return redacted
+}
+
+// configProblem is an invalid or unknown key found in a config file
+type configProblem struct {
+	line    int
+	key     string
+	message string
+	warning bool
+}
+
+func (p configProblem) String() string {
+	level := "error"
+	if p.warning {
+		level = "warning"
+	}
+	return fmt.Sprintf("%d: %s: %s", p.line, level, p.message)
+}
+
+// validateConfig checks the keys and values in a config file returning the
+// problems found ordered by line
+func validateConfig(doc *configDoc) []configProblem {
+	servers := make(map[string]bool)
+	for name := range viper.GetStringMap("servers") {
+		servers[name] = true
+	}
+	if n, ok := doc.get("servers"); ok && n.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			servers[n.Content[i].Value] = true
+		}
+	}
+
+	var problems []configProblem
+	report := func(n *yaml.Node, key string, warning bool, format string, a ...interface{}) {
+		problems = append(problems, configProblem{
+			line:    n.Line,
+			key:     key,
+			message: key + ": " + fmt.Sprintf(format, a...),
+			warning: warning,
+		})
+	}
+	check := func(kind configKind, key string, n *yaml.Node) {
+		if n.Kind != yaml.ScalarNode {
+			report(n, key, false, "expected a single value")
+			return
+		}
+		if n.Tag == "!!null" {
+			return
+		}
+		var err error
+		switch kind {
+		case kindInt:
+			if _, err = strconv.Atoi(n.Value); err != nil {
+				err = errors.New("expected a number")
+			}
+		case kindBool:
+			if _, err = strconv.ParseBool(n.Value); err != nil {
+				err = errors.New("expected true or false")
+			}
+		case kindDuration:
+			_, err = time.ParseDuration(n.Value)
+		case kindURL:
+			err = checkServerUrl(n.Value)
+		case kindOutput:
+			err = checkOutputFormat(n.Value)
+		case kindTheme:
+			_, err = highlight.LookupTheme(n.Value)
+		case kindGlob:
+			_, err = filepath.Match(n.Value, "")
+		case kindServer:
+			if !servers[n.Value] && !strings.Contains(n.Value, "://") {
+				err = fmt.Errorf("Unknown server %q", n.Value)
+			}
+		}
+		if err != nil {
+			report(n, key, false, "invalid value %q: %s", n.Value, err)
+		}
+	}
+
+	// checkRule checks the keys of a rule in the rules section
+	checkRule := func(n *yaml.Node, key string) {
+		if n.Kind != yaml.MappingNode {
+			report(n, key, false, "expected a mapping")
+			return
+		}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k, v := n.Content[i], n.Content[i+1]
+			if kind, ok := ruleKeys[k.Value]; ok {
+				check(kind, key+"."+k.Value, v)
+			} else {
+				report(k, key+"."+k.Value, true, "unknown key")
+			}
+		}
+		_, match := mappingEntry(n, "match")
+		_, language := mappingEntry(n, "language")
+		if match == nil && language == nil {
+			report(n, key, false, "missing match or language")
+		}
+	}
+
+	// walk checks each key in mapping n against the keys known
+	var walk func(n *yaml.Node, prefix string)
+	walk = func(n *yaml.Node, prefix string) {
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k, v := n.Content[i], n.Content[i+1]
+			key := prefix + k.Value
+			if kind, ok := configKeyKind(key); ok {
+				check(kind, key, v)
+				continue
+			}
+			switch {
+			case key == "rules" && v.Kind == yaml.SequenceNode:
+				for j, rule := range v.Content {
+					checkRule(rule, fmt.Sprintf("rules[%d]", j))
+				}
+			case key == "rules":
+				report(v, key, false, "expected a list of rules")
+			case isConfigSection(key) && v.Kind == yaml.MappingNode:
+				walk(v, key+".")
+			case isConfigSection(key):
+				report(v, key, false, "expected a mapping")
+			case prefix == "" && isFlagKey(key):
+			default:
+				report(k, key, true, "unknown key")
+			}
+		}
+	}
+	walk(doc.root, "")
+
+	// Every server needs a URL
+	if n, ok := doc.get("servers"); ok && n.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k, v := n.Content[i], n.Content[i+1]
+			if _, url := mappingEntry(v, "url"); v.Kind == yaml.MappingNode && url == nil {
+				report(k, "servers."+k.Value, false, "missing url")
+			}
+		}
+	}
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].line < problems[j].line
+	})
+	return problems
+}
+
+// projectConfigProblems reports the keys in a project config file that it
+// isn't allowed to set, these are ignored when the config is loaded
+func projectConfigProblems(doc *configDoc) []configProblem {
+	var problems []configProblem
+	for _, key := range doc.leaves() {
+		if projectKeyAllowed(key) {
+			continue
+		}
+		n, _ := doc.get(strings.Split(key, ".")...)
+		message := key + ": not allowed in a project config file"
+		if key == "pager" {
+			message += ", it would run a command chosen by the project"
+		}
+		problems = append(problems, configProblem{
+			line:    n.Line,
+			key:     key,
+			message: message,
+		})
+	}
+	return problems
+}
+
+// isConfigSection reports whether key is a mapping holding known keys
+func isConfigSection(key string) bool {
+	for k := range configKeys {
+		if strings.HasPrefix(k, key+".") {
+			return true
+		}
+	}
+	parts := strings.SplitN(key, ".", 3)
+	switch {
+	case parts[0] != "servers":
+		return false
+	case len(parts) < 3:
+		return true
+	case parts[2] == "headers":
+		return true
+	}
+	for k := range serverKeys {
+		if strings.HasPrefix(k, parts[2]+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// isFlagKey reports whether key is one a command flag is bound to
+func isFlagKey(key string) bool {
+	for _, prefix := range flagKeyPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,397 @@
+/*
+Copyright © 2022 Harry Law <hrryslw@pm.me>
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/h5law/paste-cli/utils"
+	"gopkg.in/yaml.v3"
+)
+
+// configDoc is a YAML config file edited in place keeping its comments and
+// the order of its keys
+type configDoc struct {
+	path string
+	root *yaml.Node
+}
+
+// Config files looked for besides the user config files
+const (
+	systemConfigFile  = "/etc/paste/config.yaml"
+	projectConfigName = ".paste.yaml"
+)
+
+// configLayer is a config file merged into the config, the values of later
+// layers override those of earlier ones
+type configLayer struct {
+	name string
+	path string
+}
+
+// configLayers are the config files read in the order they were merged and
+// configDocs their contents as merged
+var (
+	configLayers []configLayer
+	configDocs   []*configDoc
+)
+
+// projectConfigKeys are the keys a project config file can set, a repository
+// can pin its team's server and defaults but nothing that reads or writes
+// other files, runs commands or changes how servers are trusted
+var projectConfigKeys = map[string]bool{
+	"url":            true,
+	"server":         true,
+	"default-server": true,
+	"rules":          true,
+	"new-expiresIn":  true,
+	"new-filetype":   true,
+}
+
+// projectServerKeys are the keys a project config file can set for each
+// server in the servers section
+var projectServerKeys = map[string]bool{
+	"url":      true,
+	"filetype": true,
+	"expires":  true,
+}
+
+// findConfigLayers returns the config files that exist in the order they are
+// merged: system, user, legacy user and the nearest project file found
+// walking up from the current directory, or only the file given with the
+// config flag
+func findConfigLayers() ([]configLayer, error) {
+	var candidates []configLayer
+	if cfgFile != "" {
+		candidates = []configLayer{{"flag", cfgFile}}
+	} else {
+		var err error
+		if candidates, err = userConfigLayers(); err != nil {
+			return nil, err
+		}
+	}
+
+	var layers []configLayer
+	for _, layer := range candidates {
+		if exists, _ := utils.FileExists(layer.path); exists {
+			layers = append(layers, layer)
+		}
+	}
+	return layers, nil
+}
+
+// userConfigLayers returns the paths config files are looked for at when
+// none is given with the config flag
+func userConfigLayers() ([]configLayer, error) {
+	user, err := userConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	legacy, err := legacyConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	candidates := []configLayer{
+		{"system", systemConfigFile},
+		{"user", user},
+		{"user", legacy},
+	}
+	if project, ok := projectConfigFile(); ok && project != legacy {
+		candidates = append(candidates, configLayer{"project", project})
+	}
+	return candidates, nil
+}
+
+// userConfigFile returns the path of the user config file in the XDG config
+// directory
+func userConfigFile() (string, error) {
+	dir, err := utils.ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "config.yaml"), nil
+}
+
+// legacyConfigFile returns the path of the user config file in the home
+// directory used before the XDG config directory
+func legacyConfigFile() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, projectConfigName), nil
+}
+
+// projectConfigFile returns the nearest project config file in the current
+// directory or its parents
+func projectConfigFile() (string, bool) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", false
+	}
+	for {
+		path := filepath.Join(dir, projectConfigName)
+		if exists, _ := utils.FileExists(path); exists {
+			return path, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// projectKeyAllowed reports whether a project config file can set key
+func projectKeyAllowed(key string) bool {
+	if projectConfigKeys[key] {
+		return true
+	}
+	parts := strings.SplitN(key, ".", 3)
+	return len(parts) == 3 && parts[0] == "servers" && projectServerKeys[parts[2]]
+}
+
+// restrictProjectConfig removes the keys a project config file can't set,
+// returning them sorted. Servers whose URL the project changes lose the
+// headers and TLS settings of the layers below so credentials meant for one
+// server are never sent to another
+func restrictProjectConfig(project *configDoc, lower []*configDoc) []string {
+	var removed []string
+	for _, key := range project.leaves() {
+		if !projectKeyAllowed(key) {
+			project.unset(strings.Split(key, ".")...)
+			removed = append(removed, key)
+		}
+	}
+	sort.Strings(removed)
+
+	servers, ok := project.get("servers")
+	if !ok || servers.Kind != yaml.MappingNode {
+		return removed
+	}
+	for i := 0; i+1 < len(servers.Content); i += 2 {
+		name := servers.Content[i].Value
+		_, url := mappingEntry(servers.Content[i+1], "url")
+		if url == nil {
+			continue
+		}
+		// The URL in effect before the project file is the last one set
+		prev := ""
+		for _, doc := range lower {
+			if n, ok := doc.get("servers", name, "url"); ok {
+				prev = n.Value
+			}
+		}
+		if prev == url.Value {
+			continue
+		}
+		for _, doc := range lower {
+			doc.unset("servers", name, "headers")
+			doc.unset("servers", name, "tls")
+		}
+	}
+	return removed
+}
+
+// configFilePath returns the config file changes are written to: the one
+// given with the config flag, the legacy user file if it exists or the user
+// file in the XDG config directory
+func configFilePath() (string, error) {
+	if cfgFile != "" {
+		return cfgFile, nil
+	}
+	legacy, err := legacyConfigFile()
+	if err != nil {
+		return "", err
+	}
+	if exists, _ := utils.FileExists(legacy); exists {
+		return legacy, nil
+	}
+	return userConfigFile()
+}
+
+// loadConfigDoc reads the config file at path, a missing file is treated as
+// an empty one
+func loadConfigDoc(path string) (*configDoc, error) {
+	doc := &configDoc{
+		path: path,
+		root: &yaml.Node{Kind: yaml.MappingNode},
+	}
+	b, err := ioutil.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return doc, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var n yaml.Node
+	if err := yaml.Unmarshal(b, &n); err != nil {
+		return nil, fmt.Errorf("Error reading config file %s: %w", path, err)
+	}
+	if len(n.Content) > 0 {
+		if n.Content[0].Kind != yaml.MappingNode {
+			return nil, fmt.Errorf("Error reading config file %s: not a mapping", path)
+		}
+		doc.root = n.Content[0]
+	}
+	return doc, nil
+}
+
+// get returns the node at the path of keys given
+func (d *configDoc) get(keys ...string) (*yaml.Node, bool) {
+	n := d.root
+	for _, key := range keys {
+		if n.Kind != yaml.MappingNode {
+			return nil, false
+		}
+		_, value := mappingEntry(n, key)
+		if value == nil {
+			return nil, false
+		}
+		n = value
+	}
+	return n, true
+}
+
+// leaves returns the dotted keys of every value in the file that isn't a
+// mapping
+func (d *configDoc) leaves() []string {
+	var keys []string
+	var walk func(n *yaml.Node, prefix string)
+	walk = func(n *yaml.Node, prefix string) {
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key := prefix + n.Content[i].Value
+			if v := n.Content[i+1]; v.Kind == yaml.MappingNode {
+				walk(v, key+".")
+			} else {
+				keys = append(keys, key)
+			}
+		}
+	}
+	walk(d.root, "")
+	return keys
+}
+
+// set sets the value at the path of keys given creating any mappings needed
+func (d *configDoc) set(value interface{}, keys ...string) error {
+	var v yaml.Node
+	if err := v.Encode(value); err != nil {
+		return err
+	}
+	n := d.root
+	for i, key := range keys {
+		_, next := mappingEntry(n, key)
+		last := i == len(keys)-1
+		switch {
+		case next != nil && last:
+			// Keep comments attached to the old value
+			v.HeadComment, v.LineComment = next.HeadComment, next.LineComment
+			*next = v
+			return nil
+		case next == nil && last:
+			n.Content = append(n.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Value: key},
+				&v,
+			)
+			return nil
+		case next == nil:
+			next = &yaml.Node{Kind: yaml.MappingNode}
+			n.Content = append(n.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Value: key},
+				next,
+			)
+		case next.Kind != yaml.MappingNode:
+			return fmt.Errorf("Config key %s is not a mapping", key)
+		}
+		n = next
+	}
+	return nil
+}
+
+// unset removes the value at the path of keys given, reporting whether it
+// was present
+func (d *configDoc) unset(keys ...string) bool {
+	if len(keys) == 0 {
+		return false
+	}
+	parent, ok := d.get(keys[:len(keys)-1]...)
+	if !ok || parent.Kind != yaml.MappingNode {
+		return false
+	}
+	for i := 0; i+1 < len(parent.Content); i += 2 {
+		if parent.Content[i].Value == keys[len(keys)-1] {
+			parent.Content = append(parent.Content[:i], parent.Content[i+2:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// save writes the config file back readable only by the current user as it
+// may hold secrets such as headers
+func (d *configDoc) save() error {
+	b, err := d.encode()
+	if err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(d.path, b, 0600)
+}
+
+// encode returns the config file as YAML
+func (d *configDoc) encode() ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(d.root); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// mappingEntry returns the key and value nodes for key in mapping n
+func mappingEntry(n *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i], n.Content[i+1]
+		}
+	}
+	return nil, nil
+}
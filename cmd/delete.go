@@ -32,7 +32,7 @@ package cmd
 
 import (
 	"fmt"
-	"os"
+	"io"
 
 	"github.com/h5law/paste-cli/api"
 	"github.com/spf13/cobra"
@@ -41,24 +41,45 @@ import (
 
 // deleteCmd represents the delete command
 var (
-	delUuid      string
-	delAccessKey string
+	delUuid string
 
 	deleteCmd = &cobra.Command{
-		Use:   "delete",
+		Use:   "delete [uuid|url|@ref]",
 		Short: "Delete a paste",
-		Long: `Delete a paste with the given UUID from a paste-server instance provided the
-access key provided matches.`,
+		Long: `Delete a paste with the given UUID or paste URL from a paste-server instance
+provided the access key provided matches, by default the access key recorded
+in the local ledger is used. Pastes in the ledger can also be given as @last,
+@~n for the one n before it or @name for a named paste.`,
+		Args: cobra.MaximumNArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
-			resp, err := api.DeletePaste()
+			// Read the access key or look up a stored one
+			ref := pasteRef(args, "del")
+			client := refClient(ref)
+			uuid := ref.UUID
+			accessKey, err := lookupAccessKey(client, uuid, "del")
 			if err != nil {
-				fmt.Println(err)
-				os.Exit(1)
+				exitWithError(err)
 			}
-			if resp != "" {
-				fmt.Println(resp)
-			} else {
-				fmt.Println("Paste deleted")
+
+			ctx, cancel := requestContext(cmd)
+			defer cancel()
+			resp, err := client.DeletePaste(ctx, api.DeleteRequest{
+				UUID:      uuid,
+				AccessKey: accessKey,
+			})
+			if err != nil {
+				exitWithError(err)
+			}
+			forgetPaste(client, uuid)
+			if resp == "" {
+				resp = "Paste deleted"
+			}
+			err = printResult(deleteResult{UUID: uuid, Message: resp}, func(w io.Writer) error {
+				_, err := fmt.Fprintln(w, resp)
+				return err
+			})
+			if err != nil {
+				exitWithError(err)
 			}
 		},
 	}
@@ -72,20 +93,10 @@ func init() {
 		"uuid",
 		"u",
 		"",
-		"UUID of paste to delete",
-	)
-	deleteCmd.MarkFlagRequired("uuid")
-	deleteCmd.Flags().StringVarP(
-		&delAccessKey,
-		"access-key",
-		"a",
-		"",
-		"Access key needed to delete paste",
+		"UUID, URL or @ref of paste to delete",
 	)
-	deleteCmd.MarkFlagRequired("access-key")
+	addAccessKeyFlags(deleteCmd, "del", "delete")
 
-	viper.BindPFlag("del-accessKey", deleteCmd.Flags().Lookup("access-key"))
 	viper.BindPFlag("del-uuid", deleteCmd.Flags().Lookup("uuid"))
-	viper.SetDefault("del-accessKey", "")
 	viper.SetDefault("del-uuid", "")
 }
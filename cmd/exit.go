@@ -0,0 +1,136 @@
+/*
+Copyright © 2022 Harry Law <hrryslw@pm.me>
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/h5law/paste-cli/api"
+	"github.com/h5law/paste-cli/keystore"
+)
+
+// Exit codes returned by the paste command, documented in the README so
+// scripts can branch on them
+const (
+	exitOK          = 0
+	exitError       = 1
+	exitUsage       = 2
+	exitNotFound    = 3
+	exitForbidden   = 4
+	exitExpired     = 5
+	exitRateLimited = 6
+	exitNetwork     = 7
+	exitServer      = 8
+	exitInvalid     = 9
+	exitTimeout     = 124
+	exitInterrupted = 130
+)
+
+// codedError is an error with the exit code the command should return
+type codedError struct {
+	err  error
+	code int
+}
+
+func (e *codedError) Error() string { return e.err.Error() }
+func (e *codedError) Unwrap() error { return e.err }
+
+// usageError marks an error in how the command was called
+func usageError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &codedError{err, exitUsage}
+}
+
+// invalidError marks an error in a value given to the command or set in the
+// config
+func invalidError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &codedError{err, exitInvalid}
+}
+
+// exitCode maps an error to the exit code of the command
+func exitCode(err error) int {
+	var coded *codedError
+	var netErr net.Error
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.As(err, &coded):
+		return coded.code
+	case errors.Is(err, api.ErrNotFound):
+		return exitNotFound
+	case errors.Is(err, api.ErrForbidden),
+		errors.Is(err, api.ErrEncrypted),
+		errors.Is(err, api.ErrDecrypt),
+		errors.Is(err, api.ErrPasswordRequired),
+		errors.Is(err, api.ErrNoIdentity),
+		errors.Is(err, keystore.ErrWrongPassphrase):
+		return exitForbidden
+	case errors.Is(err, api.ErrExpired):
+		return exitExpired
+	case errors.Is(err, api.ErrRateLimited):
+		return exitRateLimited
+	case errors.Is(err, api.ErrInvalid):
+		return exitInvalid
+	case errors.Is(err, api.ErrServer):
+		return exitServer
+	case errors.Is(err, context.DeadlineExceeded):
+		return exitTimeout
+	case errors.Is(err, context.Canceled):
+		return exitInterrupted
+	case errors.As(err, &netErr):
+		// Checked after the context errors which net errors can wrap
+		return exitNetwork
+	}
+	return exitError
+}
+
+// exitWithError prints the error to stderr and exits with the matching exit
+// code, errors are printed even when quiet
+func exitWithError(err error) {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		fmt.Fprintln(os.Stderr, "Request timed out:", err)
+	case errors.Is(err, context.Canceled):
+		fmt.Fprintln(os.Stderr, "Request cancelled")
+	default:
+		fmt.Fprintln(os.Stderr, err)
+	}
+	os.Exit(exitCode(err))
+}
@@ -31,29 +31,68 @@ POSSIBILITY OF SUCH DAMAGE.
 package cmd
 
 import (
+	"bytes"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"os"
 
 	"github.com/h5law/paste-cli/api"
+	"github.com/h5law/paste-cli/highlight"
+	"github.com/h5law/paste-cli/keys"
+	"github.com/h5law/paste-cli/utils"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
 // getCmd represents the get command
 var (
-	getUuid    string
-	getVerbose bool
+	getUuid     string
+	getVerbose  bool
+	getRaw      bool
+	getOutFile  string
+	getKey      string
+	getPassword bool
+	getPassFile string
+	getIdentity string
+	getColor    string
+	getNoPager  bool
 
 	getCmd = &cobra.Command{
-		Use:   "get",
+		Use:   "get [uuid|url|@ref]",
 		Short: "Retrieve a paste",
-		Long:  `Retrieve a paste from a paste-server instance with the given UUID`,
+		Long: `Retrieve a paste from a paste-server instance with the given UUID or
+paste URL, encrypted pastes are decrypted with the key in the URL fragment
+or given with the key flag. The paste is fetched from the server in a paste
+URL, from any instance, rather than the server in the config. Pastes in the
+ledger can also be given as @last, @~n for the one n before it or @name for
+a named paste.
+
+Text written to a terminal is syntax highlighted for the filetype of the
+paste using the theme set in the config, unless NO_COLOR is set. Output
+taller than the terminal is shown through the pager in the user or system
+config or PASTE_PAGER, $PAGER or less -R. A project config file can't set the
+pager as it would run a command chosen by the repository.`,
+		Args: cobra.MaximumNArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
+			ref := pasteRef(args, "get")
+			uuid, key := ref.UUID, ref.Key
+			color, err := colorOutput(viper.GetString("get-color"))
+			if err != nil {
+				exitWithError(err)
+			}
+			if k := viper.GetString("get-key"); k != "" {
+				if key, err = api.DecodeKey(k); err != nil {
+					exitWithError(invalidError(err))
+				}
+			}
+
 			// Get response and load into struct
-			resp, err := api.GetPaste()
+			ctx, cancel := requestContext(cmd)
+			defer cancel()
+			resp, err := refClient(ref).GetPaste(ctx, uuid)
 			if err != nil {
-				fmt.Println(err)
-				os.Exit(1)
+				exitWithError(err)
 			}
 
 			// If verbose set create map for other values
@@ -66,17 +105,111 @@ var (
 				m["expiresAt"] = resp.ExpiresAt
 			}
 
-			// Print map if verbose
+			// Print map if verbose, the header is written with the content
+			// so it stays at the top in the pager
+			var out bytes.Buffer
 			if verbose {
-				fmt.Printf("uuid:      %s\n", viper.GetString("get-uuid"))
-				fmt.Printf("filetype:  %s\n", m["filetype"])
-				fmt.Printf("expiresAt: %s\n", m["expiresAt"])
-				fmt.Println()
+				fmt.Fprintf(&out, "uuid:      %s\n", uuid)
+				fmt.Fprintf(&out, "filetype:  %s\n", m["filetype"])
+				fmt.Fprintf(&out, "expiresAt: %s\n", m["expiresAt"])
+				fmt.Fprintln(&out)
+			}
+
+			// Decode or decrypt content and choose where to write it
+			var content []byte
+			passFile := viper.GetString("get-passwordFile")
+			usePassword := viper.GetBool("get-password") || passFile != ""
+			switch {
+			case resp.IsPasswordProtected():
+				var password []byte
+				if password, err = readPassword(passFile, false); err != nil {
+					exitWithError(err)
+				}
+				content, err = resp.DecryptPassword(password)
+			case resp.IsEncryptedToRecipients():
+				var path string
+				var identities []*api.Identity
+				if path, err = identityPath(viper.GetString("get-identity")); err != nil {
+					exitWithError(err)
+				}
+				if identities, err = keys.LoadIdentities(path); err != nil {
+					exitWithError(err)
+				}
+				content, err = resp.DecryptIdentity(identities...)
+			case usePassword:
+				err = usageError(errors.New("Paste is not password protected"))
+			case resp.IsEncrypted() && key == nil:
+				err = &codedError{errors.New(
+					"Paste is encrypted, use the paste URL or --key to decrypt it",
+				), exitForbidden}
+			case resp.IsEncrypted():
+				content, err = resp.Decrypt(key)
+			default:
+				content, err = resp.Bytes()
+			}
+			if err != nil {
+				exitWithError(err)
 			}
+			binary := api.IsBinary(content)
+			outFile := viper.GetString("get-outFile")
 
-			// Print content slice
-			for _, v := range resp.Content {
-				fmt.Println(v)
+			// Machine readable output carries the content in the result and
+			// is never highlighted or paged
+			if machineOutput() {
+				if outFile != "" {
+					if err := os.WriteFile(outFile, content, 0644); err != nil {
+						exitWithError(err)
+					}
+				}
+				result := getResult{
+					UUID:      uuid,
+					FileType:  resp.FileType,
+					ExpiresAt: resp.ExpiresAt,
+					Encoding:  "utf-8",
+					Content:   string(content),
+				}
+				if binary {
+					result.Encoding = "base64"
+					result.Content = base64.StdEncoding.EncodeToString(content)
+				}
+				if err := printResult(result, nil); err != nil {
+					exitWithError(err)
+				}
+				return
+			}
+			if outFile != "" {
+				if err := os.WriteFile(outFile, content, 0644); err != nil {
+					exitWithError(err)
+				}
+				if _, err := os.Stdout.Write(out.Bytes()); err != nil {
+					exitWithError(err)
+				}
+				return
+			}
+			if binary && utils.IsOutputToTerminal() {
+				exitWithError(usageError(errors.New(
+					"Paste is binary, use --output-file to write it to a file",
+				)))
+			}
+
+			// Write content exactly as stored when raw or binary, otherwise
+			// make sure the output ends in a newline
+			if !viper.GetBool("get-raw") && !binary &&
+				len(content) > 0 && content[len(content)-1] != '\n' {
+				content = append(content, '\n')
+			}
+
+			// Highlight the syntax of text unless raw
+			if color && !viper.GetBool("get-raw") && !binary {
+				theme, err := highlight.LookupTheme(viper.GetString("theme"))
+				if err != nil {
+					exitWithError(invalidError(err))
+				}
+				content = highlight.Highlight(content, resp.FileType, theme, colorMode())
+			}
+			out.Write(content)
+			if err := writePaged(out.Bytes(), !viper.GetBool("get-noPager")); err != nil {
+				exitWithError(err)
 			}
 		},
 	}
@@ -90,9 +223,8 @@ func init() {
 		"uuid",
 		"u",
 		"",
-		"UUID of paste to fetch",
+		"UUID, URL or @ref of paste to fetch",
 	)
-	getCmd.MarkFlagRequired("uuid")
 
 	getCmd.Flags().BoolVarP(
 		&getVerbose,
@@ -101,9 +233,79 @@ func init() {
 		false,
 		"Print detailed output",
 	)
+	getCmd.Flags().BoolVar(
+		&getRaw,
+		"raw",
+		false,
+		"Write the content exactly as stored",
+	)
+	getCmd.MarkFlagsMutuallyExclusive("verbose", "raw")
+	getCmd.Flags().StringVarP(
+		&getKey,
+		"key",
+		"k",
+		"",
+		"Key to decrypt an encrypted paste with",
+	)
+	getCmd.Flags().BoolVar(
+		&getPassword,
+		"password",
+		false,
+		"Decrypt the paste with a password (prompted or from $"+passwordEnv+")",
+	)
+	getCmd.Flags().StringVar(
+		&getPassFile,
+		"password-file",
+		"",
+		"Decrypt the paste with the password in the file given",
+	)
+	getCmd.Flags().StringVarP(
+		&getIdentity,
+		"identity",
+		"i",
+		"",
+		"Identity file to decrypt the paste with (default is the identity file)",
+	)
+	getCmd.Flags().StringVar(
+		&getColor,
+		"color",
+		"auto",
+		"Highlight the syntax of the paste: auto, always or never",
+	)
+	getCmd.Flags().BoolVar(
+		&getNoPager,
+		"no-pager",
+		false,
+		"Don't page output taller than the terminal",
+	)
+	getCmd.Flags().StringVarP(
+		&getOutFile,
+		"output-file",
+		"o",
+		"",
+		"Write the content to a file instead of stdout",
+	)
 
 	viper.BindPFlag("get-uuid", getCmd.Flags().Lookup("uuid"))
 	viper.BindPFlag("get-verbose", getCmd.Flags().Lookup("verbose"))
+	viper.BindPFlag("get-raw", getCmd.Flags().Lookup("raw"))
+	viper.BindPFlag("get-outFile", getCmd.Flags().Lookup("output-file"))
+	viper.BindPFlag("get-key", getCmd.Flags().Lookup("key"))
+	viper.BindPFlag("get-password", getCmd.Flags().Lookup("password"))
+	viper.BindPFlag("get-passwordFile", getCmd.Flags().Lookup("password-file"))
+	viper.BindPFlag("get-identity", getCmd.Flags().Lookup("identity"))
+	viper.BindPFlag("get-color", getCmd.Flags().Lookup("color"))
+	viper.BindPFlag("get-noPager", getCmd.Flags().Lookup("no-pager"))
 	viper.SetDefault("get-uuid", "")
 	viper.SetDefault("get-verbose", false)
+	viper.SetDefault("get-raw", false)
+	viper.SetDefault("get-outFile", "")
+	viper.SetDefault("get-key", "")
+	viper.SetDefault("get-password", false)
+	viper.SetDefault("get-passwordFile", "")
+	viper.SetDefault("get-identity", "")
+	viper.SetDefault("get-color", "auto")
+	viper.SetDefault("get-noPager", false)
+	viper.SetDefault("pager", "")
+	viper.SetDefault("theme", highlight.DefaultTheme)
 }
@@ -0,0 +1,125 @@
+/*
+Copyright © 2022 Harry Law <hrryslw@pm.me>
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/h5law/paste-cli/api"
+	"github.com/h5law/paste-cli/keys"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// keygenCmd represents the keygen command
+var (
+	keygenFile  string
+	keygenForce bool
+
+	keygenCmd = &cobra.Command{
+		Use:   "keygen",
+		Short: "Generate an identity for encrypted pastes",
+		Long: `Generate a new X25519 identity used to decrypt pastes encrypted to its
+public key and write it to the identity file.
+
+The public key printed can be shared with teammates who add it to their
+recipients with the recipients add command.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			path, err := identityPath(viper.GetString("keygen-file"))
+			if err != nil {
+				exitWithError(err)
+			}
+
+			id, err := api.GenerateIdentity()
+			if err != nil {
+				exitWithError(err)
+			}
+			force := viper.GetBool("keygen-force")
+			if err := keys.WriteIdentity(path, id, force); err != nil {
+				exitWithError(err)
+			}
+
+			result := keygenResult{
+				Identity:  path,
+				PublicKey: id.Recipient().String(),
+			}
+			err = printResult(result, func(w io.Writer) error {
+				_, err := fmt.Fprintf(
+					w,
+					"identity:  \t%s\npublicKey: \t%s\n",
+					result.Identity,
+					result.PublicKey,
+				)
+				return err
+			})
+			if err != nil {
+				exitWithError(err)
+			}
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(keygenCmd)
+
+	keygenCmd.Flags().StringVarP(
+		&keygenFile,
+		"file",
+		"f",
+		"",
+		"Path to write the identity to (default is the identity file)",
+	)
+	keygenCmd.Flags().BoolVar(
+		&keygenForce,
+		"force",
+		false,
+		"Overwrite an existing identity file",
+	)
+
+	viper.BindPFlag("keygen-file", keygenCmd.Flags().Lookup("file"))
+	viper.BindPFlag("keygen-force", keygenCmd.Flags().Lookup("force"))
+	viper.SetDefault("keygen-file", "")
+	viper.SetDefault("keygen-force", false)
+}
+
+// identityPath returns the path given or the identity file set in the config
+// falling back to the default identity file
+func identityPath(path string) (string, error) {
+	if path == "" {
+		path = viper.GetString("identity-file")
+	}
+	if path == "" {
+		return keys.DefaultIdentityPath()
+	}
+	return path, nil
+}
@@ -0,0 +1,348 @@
+/*
+Copyright © 2022 Harry Law <hrryslw@pm.me>
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/h5law/paste-cli/keystore"
+	"github.com/h5law/paste-cli/ledger"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// keystoreCmd represents the keystore command
+var (
+	keystoreTtl         time.Duration
+	keystoreSessionFile string
+	keystoreAfter       time.Duration
+
+	keystoreCmd = &cobra.Command{
+		Use:   "keystore",
+		Short: "Manage the encrypted store of access keys",
+		Long: `Manage the keystore which keeps the access keys of created pastes
+encrypted under a master passphrase instead of in plaintext in the ledger.
+
+Once unlocked the keystore stays unlocked for the TTL set in the config
+(15 minutes by default) so the passphrase isn't needed for every command.
+The key is cached in a file in $XDG_RUNTIME_DIR, or a private directory in
+the system temporary directory, which a background paste process removes
+when the TTL ends.`,
+	}
+
+	keystoreInitCmd = &cobra.Command{
+		Use:   "init",
+		Short: "Create the keystore",
+		Long: `Create the keystore protected by a new passphrase, access keys and
+encryption keys already recorded in the ledger are moved into it.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			path, err := keystorePath()
+			if err != nil {
+				exitWithError(err)
+			}
+			passphrase, err := readSecret(secretSource{
+				name: "Keystore passphrase",
+				env:  passphraseEnv,
+			}, true)
+			if err != nil {
+				exitWithError(err)
+			}
+			ks, err := keystore.Create(path, passphrase)
+			if err != nil {
+				exitWithError(err)
+			}
+			saveKeystoreSession(ks)
+
+			// Move access keys and encryption keys out of the ledger
+			moved := 0
+			l, err := openLedger()
+			if err == nil {
+				err = l.Modify(func(entries []ledger.Entry) []ledger.Entry {
+					for i, e := range entries {
+						if e.Key != "" && ks.SetKey(e.Server, e.UUID, e.Key) == nil {
+							entries[i].Key = ""
+						}
+						if e.AccessKey == "" {
+							continue
+						}
+						if err := ks.Set(e.Server, e.UUID, e.AccessKey); err != nil {
+							continue
+						}
+						entries[i].AccessKey = ""
+						moved++
+					}
+					return entries
+				})
+			}
+			if err != nil {
+				exitWithError(err)
+			}
+
+			notef("Keystore created at %s", path)
+			if moved > 0 {
+				notef("Moved %d access keys from the ledger", moved)
+			}
+		},
+	}
+
+	keystoreUnlockCmd = &cobra.Command{
+		Use:   "unlock",
+		Short: "Unlock the keystore for a while",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			if cmd.Flags().Changed("ttl") {
+				viper.Set("keystore.ttl", keystoreTtl)
+			}
+			ks, _, err := unlockKeystore()
+			if err != nil {
+				exitWithError(err)
+			}
+			if ks == nil {
+				exitWithError(usageError(errors.New("No keystore found, create one with keystore init")))
+			}
+
+			// Cache the key even if already unlocked so an unlocked keystore gets the new TTL
+			if err := cacheKeystoreSession(ks); err != nil {
+				exitWithError(fmt.Errorf("Unable to cache keystore session: %w", err))
+			}
+			notef("Keystore unlocked for %s", viper.GetDuration("keystore.ttl"))
+		},
+	}
+
+	// keystoreExpireSessionCmd is run in the background by the paste command
+	// itself to remove the session file when its TTL ends
+	keystoreExpireSessionCmd = &cobra.Command{
+		Use:    "expire-session",
+		Short:  "Remove the cached keystore key once it has expired",
+		Args:   cobra.NoArgs,
+		Hidden: true,
+		Run: func(cmd *cobra.Command, args []string) {
+			time.Sleep(keystoreAfter)
+			if err := keystore.ExpireSession(keystoreSessionFile); err != nil {
+				exitWithError(err)
+			}
+		},
+	}
+
+	keystoreLockCmd = &cobra.Command{
+		Use:   "lock",
+		Short: "Lock the keystore",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			path, err := keystore.SessionPath()
+			if err != nil {
+				exitWithError(err)
+			}
+			if err := keystore.ClearSession(path); err != nil {
+				exitWithError(err)
+			}
+		},
+	}
+
+	keystoreListCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List the pastes with stored access keys",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			ks, err := openKeystore()
+			if err != nil {
+				exitWithError(err)
+			}
+			if ks == nil {
+				exitWithError(usageError(errors.New("No keystore found, create one with keystore init")))
+			}
+			keys, err := ks.Entries()
+			if err != nil {
+				exitWithError(err)
+			}
+			names := make([]string, 0, len(keys))
+			for name := range keys {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			results := make([]keystoreResult, len(names))
+			for i, name := range names {
+				server, uuid, _ := strings.Cut(name, " ")
+				results[i] = keystoreResult{UUID: uuid, Server: server}
+			}
+			err = printResult(results, func(out io.Writer) error {
+				w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+				for _, r := range results {
+					fmt.Fprintf(w, "%s\t%s\n", r.UUID, r.Server)
+				}
+				return w.Flush()
+			})
+			if err != nil {
+				exitWithError(err)
+			}
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(keystoreCmd)
+	keystoreCmd.AddCommand(keystoreInitCmd)
+	keystoreCmd.AddCommand(keystoreUnlockCmd)
+	keystoreCmd.AddCommand(keystoreLockCmd)
+	keystoreCmd.AddCommand(keystoreListCmd)
+	keystoreCmd.AddCommand(keystoreExpireSessionCmd)
+
+	keystoreUnlockCmd.Flags().DurationVar(
+		&keystoreTtl,
+		"ttl",
+		15*time.Minute,
+		"Time to keep the keystore unlocked for",
+	)
+	keystoreExpireSessionCmd.Flags().StringVar(
+		&keystoreSessionFile,
+		"session-file",
+		"",
+		"Session file to remove",
+	)
+	keystoreExpireSessionCmd.Flags().DurationVar(
+		&keystoreAfter,
+		"after",
+		0,
+		"Time to wait before removing the session file",
+	)
+	keystoreExpireSessionCmd.MarkFlagRequired("session-file")
+
+	viper.SetDefault("keystore.file", "")
+	viper.SetDefault("keystore.ttl", 15*time.Minute)
+}
+
+// keystorePath returns the keystore file set in the config or the default
+func keystorePath() (string, error) {
+	if path := viper.GetString("keystore.file"); path != "" {
+		return path, nil
+	}
+	return keystore.DefaultPath()
+}
+
+// openKeystore opens the keystore using the cached session key or by asking
+// for the passphrase, nil is returned if no keystore has been created
+func openKeystore() (*keystore.Keystore, error) {
+	ks, prompted, err := unlockKeystore()
+	if prompted {
+		saveKeystoreSession(ks)
+	}
+	return ks, err
+}
+
+// unlockKeystore opens the keystore like openKeystore without caching its
+// key, reporting whether the passphrase had to be read
+func unlockKeystore() (*keystore.Keystore, bool, error) {
+	path, err := keystorePath()
+	if err != nil {
+		return nil, false, err
+	}
+	if exists, err := keystore.Exists(path); err != nil || !exists {
+		return nil, false, err
+	}
+
+	sessionPath, err := keystore.SessionPath()
+	if err != nil {
+		return nil, false, err
+	}
+	if ks, ok := keystore.LoadSession(sessionPath, path); ok {
+		return ks, false, nil
+	}
+
+	passphrase, err := readSecret(secretSource{
+		name: "Keystore passphrase",
+		env:  passphraseEnv,
+	}, false)
+	if err != nil {
+		return nil, false, err
+	}
+	ks, err := keystore.Open(path, passphrase)
+	if err != nil {
+		return nil, false, err
+	}
+	return ks, true, nil
+}
+
+// saveKeystoreSession keeps the keystore unlocked for the TTL in the config,
+// failing to do so only prints a warning
+func saveKeystoreSession(ks *keystore.Keystore) {
+	if err := cacheKeystoreSession(ks); err != nil {
+		warnf("unable to cache keystore session: %s", err)
+	}
+}
+
+// cacheKeystoreSession caches the key of the keystore for the TTL in the
+// config and starts the process removing it when the TTL ends
+func cacheKeystoreSession(ks *keystore.Keystore) error {
+	ttl := viper.GetDuration("keystore.ttl")
+	if ttl <= 0 {
+		return nil
+	}
+	path, err := keystore.SessionPath()
+	if err != nil {
+		return err
+	}
+	if err := keystore.SaveSession(path, ks, ttl); err != nil {
+		return err
+	}
+	return startSessionExpiry(path, ttl)
+}
+
+// startSessionExpiry starts a detached paste process which removes the
+// session file once ttl has passed so the key doesn't stay on disk
+func startSessionExpiry(path string, ttl time.Duration) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	c := exec.Command(
+		exe,
+		"keystore",
+		"expire-session",
+		"--session-file",
+		path,
+		"--after",
+		ttl.String(),
+	)
+	detach(c)
+	if err := c.Start(); err != nil {
+		return err
+	}
+	return c.Process.Release()
+}
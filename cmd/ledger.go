@@ -0,0 +1,141 @@
+/*
+Copyright © 2022 Harry Law <hrryslw@pm.me>
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+package cmd
+
+import (
+	"path/filepath"
+	"time"
+
+	"github.com/h5law/paste-cli/api"
+	"github.com/h5law/paste-cli/ledger"
+	"github.com/spf13/viper"
+)
+
+// openLedger opens the ledger file set in the config or the default one
+func openLedger() (*ledger.Ledger, error) {
+	path := viper.GetString("ledger-file")
+	if path == "" {
+		var err error
+		if path, err = ledger.DefaultPath(); err != nil {
+			return nil, err
+		}
+	}
+	return ledger.Open(path), nil
+}
+
+// recordPaste adds a newly created paste to the ledger under the name given,
+// if any, keeping its access key and the key it was encrypted with in the
+// keystore if one has been created, failing to do so only prints a warning as
+// the paste has already been created
+func recordPaste(
+	client *api.Client,
+	resp api.CreateResult,
+	fileType, source, name string,
+) {
+	if source != "" {
+		if abs, err := filepath.Abs(source); err == nil {
+			source = abs
+		}
+	}
+
+	// Never fall back to the ledger for keys once a keystore exists
+	accessKey := resp.AccessKey
+	var key string
+	if resp.Key != nil {
+		key = api.EncodeKey(resp.Key)
+	}
+	ks, err := openKeystore()
+	if ks != nil {
+		err = ks.Set(client.BaseUrl(), resp.UUID, accessKey)
+	}
+	if ks != nil && err == nil && key != "" {
+		err = ks.SetKey(client.BaseUrl(), resp.UUID, key)
+	}
+	if ks != nil || err != nil {
+		accessKey, key = "", ""
+	}
+	if err != nil {
+		warnf("unable to save access key in keystore: %s", err)
+	}
+
+	l, err := openLedger()
+	if err == nil {
+		err = l.Add(ledger.Entry{
+			UUID:      resp.UUID,
+			Server:    client.BaseUrl(),
+			AccessKey: accessKey,
+			Encrypted: resp.Key != nil,
+			Key:       key,
+			FileType:  fileType,
+			ExpiresAt: resp.ExpiresAt,
+			Source:    source,
+			CreatedAt: time.Now(),
+			Name:      name,
+		})
+	}
+	if err != nil {
+		warnf("unable to record paste in ledger: %s", err)
+	}
+}
+
+// forgetPaste removes a deleted paste from the ledger and keystore, failures
+// only print a warning
+func forgetPaste(client *api.Client, uuid string) {
+	updateLedger(client, uuid, func(*ledger.Entry) bool { return false })
+	ks, err := openKeystore()
+	if ks != nil {
+		err = ks.Delete(client.BaseUrl(), uuid)
+	}
+	if err != nil {
+		warnf("unable to update keystore: %s", err)
+	}
+}
+
+// updateLedger applies fn to the ledger entries of the paste with the uuid
+// given, fn returns false to remove the entry, failures only print a warning
+func updateLedger(client *api.Client, uuid string, fn func(*ledger.Entry) bool) {
+	l, err := openLedger()
+	if err == nil {
+		err = l.Modify(func(entries []ledger.Entry) []ledger.Entry {
+			kept := entries[:0]
+			for _, e := range entries {
+				if e.Server == client.BaseUrl() && e.UUID == uuid && !fn(&e) {
+					continue
+				}
+				kept = append(kept, e)
+			}
+			return kept
+		})
+	}
+	if err != nil {
+		warnf("unable to update ledger: %s", err)
+	}
+}
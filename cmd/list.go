@@ -0,0 +1,240 @@
+/*
+Copyright © 2022 Harry Law <hrryslw@pm.me>
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/h5law/paste-cli/ledger"
+	"github.com/h5law/paste-cli/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// listCmd represents the list command
+var (
+	listFileType       string
+	listExpired        bool
+	listExpiringWithin string
+	listSort           string
+	listReverse        bool
+	listJson           bool
+
+	// listSortKeys maps the values of the sort flag to comparisons of entries
+	listSortKeys = map[string]func(a, b ledger.Entry) bool{
+		"created": func(a, b ledger.Entry) bool {
+			return a.CreatedAt.Before(b.CreatedAt)
+		},
+		"expires": func(a, b ledger.Entry) bool {
+			return a.ExpiresAt.Before(b.ExpiresAt)
+		},
+		"uuid": func(a, b ledger.Entry) bool {
+			return a.UUID < b.UUID
+		},
+		"filetype": func(a, b ledger.Entry) bool {
+			return a.FileType < b.FileType
+		},
+		"server": func(a, b ledger.Entry) bool {
+			return a.Server < b.Server
+		},
+		"source": func(a, b ledger.Entry) bool {
+			return a.Source < b.Source
+		},
+	}
+
+	listCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List created pastes",
+		Long: `List the pastes created with the new command recorded in the local
+ledger, showing the time left until each paste expires. Only the pastes on
+the server chosen are listed when the server flag or PASTE_SERVER is set.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			less, ok := listSortKeys[viper.GetString("list-sort")]
+			if !ok {
+				exitWithError(invalidError(fmt.Errorf(
+					"Invalid sort key: %s (one of created, expires, uuid, filetype, server, source)",
+					viper.GetString("list-sort"),
+				)))
+			}
+			var within time.Duration
+			if s := viper.GetString("list-expiringWithin"); s != "" {
+				var err error
+				if within, err = utils.ParseDuration(s); err != nil {
+					exitWithError(invalidError(err))
+				}
+			}
+
+			l, err := openLedger()
+			if err != nil {
+				exitWithError(err)
+			}
+			entries, err := l.Entries()
+			if err != nil {
+				exitWithError(err)
+			}
+
+			// Filter entries
+			now := time.Now()
+			var server string
+			if viper.GetString("server") != "" {
+				server = newClient().BaseUrl()
+			}
+			fileType := viper.GetString("list-filetype")
+			expired := viper.GetBool("list-expired")
+			var matched []ledger.Entry
+			for _, e := range entries {
+				left := e.ExpiresAt.Sub(now)
+				switch {
+				case server != "" && e.Server != server:
+				case fileType != "" && e.FileType != fileType:
+				case expired && left > 0:
+				case within > 0 && (left <= 0 || left > within):
+				default:
+					matched = append(matched, e)
+				}
+			}
+
+			// Sort entries
+			reverse := viper.GetBool("list-reverse")
+			sort.SliceStable(matched, func(i, j int) bool {
+				if reverse {
+					return less(matched[j], matched[i])
+				}
+				return less(matched[i], matched[j])
+			})
+
+			// The json flag predates the output flag
+			if viper.GetBool("list-json") {
+				outputFormat = outputJson
+			}
+			if matched == nil {
+				matched = []ledger.Entry{}
+			}
+			err = printResult(matched, func(out io.Writer) error {
+				w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+				fmt.Fprintln(w, "UUID\tFILETYPE\tSERVER\tCREATED\tEXPIRES IN\tSOURCE\tNAME")
+				for _, e := range matched {
+					expiresIn := "expired"
+					if left := e.ExpiresAt.Sub(now); left > 0 {
+						expiresIn = utils.FormatDuration(left)
+					}
+					source := "-"
+					if e.Source != "" {
+						source = filepath.Base(e.Source)
+					}
+					name := "-"
+					if e.Name != "" {
+						name = "@" + e.Name
+					}
+					fmt.Fprintf(
+						w,
+						"%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+						e.UUID,
+						e.FileType,
+						e.Server,
+						e.CreatedAt.Local().Format("2006-01-02 15:04"),
+						expiresIn,
+						source,
+						name,
+					)
+				}
+				return w.Flush()
+			})
+			if err != nil {
+				exitWithError(err)
+			}
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(listCmd)
+
+	listCmd.Flags().StringVarP(
+		&listFileType,
+		"filetype",
+		"t",
+		"",
+		"Only list pastes with the filetype given",
+	)
+	listCmd.Flags().BoolVar(
+		&listExpired,
+		"expired",
+		false,
+		"Only list expired pastes",
+	)
+	listCmd.Flags().StringVar(
+		&listExpiringWithin,
+		"expiring-within",
+		"",
+		"Only list pastes expiring within the duration given (e.g. 2d, 12h)",
+	)
+	listCmd.MarkFlagsMutuallyExclusive("expired", "expiring-within")
+	listCmd.Flags().StringVar(
+		&listSort,
+		"sort",
+		"created",
+		"Sort by created, expires, uuid, filetype, server or source",
+	)
+	listCmd.Flags().BoolVarP(
+		&listReverse,
+		"reverse",
+		"r",
+		false,
+		"Reverse the sort order",
+	)
+	listCmd.Flags().BoolVar(
+		&listJson,
+		"json",
+		false,
+		"Print the pastes as JSON",
+	)
+	listCmd.Flags().MarkDeprecated("json", "use --output json instead")
+
+	viper.BindPFlag("list-filetype", listCmd.Flags().Lookup("filetype"))
+	viper.BindPFlag("list-expired", listCmd.Flags().Lookup("expired"))
+	viper.BindPFlag("list-expiringWithin", listCmd.Flags().Lookup("expiring-within"))
+	viper.BindPFlag("list-sort", listCmd.Flags().Lookup("sort"))
+	viper.BindPFlag("list-reverse", listCmd.Flags().Lookup("reverse"))
+	viper.BindPFlag("list-json", listCmd.Flags().Lookup("json"))
+	viper.SetDefault("list-filetype", "")
+	viper.SetDefault("list-expired", false)
+	viper.SetDefault("list-expiringWithin", "")
+	viper.SetDefault("list-sort", "created")
+	viper.SetDefault("list-reverse", false)
+	viper.SetDefault("list-json", false)
+}
@@ -32,9 +32,13 @@ package cmd
 
 import (
 	"fmt"
-	"os"
+	"io"
+	"time"
 
 	"github.com/h5law/paste-cli/api"
+	"github.com/h5law/paste-cli/filetype"
+	"github.com/h5law/paste-cli/keys"
+	"github.com/h5law/paste-cli/redact"
 	"github.com/h5law/paste-cli/utils"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -42,9 +46,16 @@ import (
 
 // newCmd represents the new command
 var (
-	newFilePath  string
-	newFileType  string
-	newExpiresIn int
+	newFilePath   string
+	newFileType   string
+	newExpiresIn  int
+	newEncrypt    bool
+	newPassword   bool
+	newPassFile   string
+	newRecipient  []string
+	newRedact     bool
+	newDetectOnly bool
+	newName       string
 
 	newCmd = &cobra.Command{
 		Use:   "new",
@@ -53,24 +64,156 @@ var (
 the content to the paste-server.
 
 Running this command will return the UUID, expiration date and
-access key for the paste created.`,
+access key for the paste created. The paste is also recorded in the local
+ledger so its access key doesn't need to be given to update or delete it,
+name it with the name flag to refer to it as @name later.
+
+The filetype is detected from the file name, shebang line, vim or emacs
+modelines or the content itself. Unless set with flags the filetype, expiry,
+encryption and redaction of the paste come from the first rule in the config
+matching it, then the filetype detected and the defaults of the server.`,
 		Run: func(cmd *cobra.Command, args []string) {
+			name := viper.GetString("new-name")
+			if name != "" {
+				if err := checkAliasName(name); err != nil {
+					exitWithError(err)
+				}
+			}
+
 			// Prioritise pipe input
+			filePath := viper.GetString("new-file")
 			if pipe := utils.IsInputFromPipe(); pipe {
-				viper.Set("file", "")
+				filePath = ""
+			}
+			content, err := utils.ReadContent(filePath)
+			if err != nil {
+				exitWithError(invalidError(err))
+			}
+
+			// Use the defaults of the first matching rule then the filetype
+			// detected and the defaults of the server unless the flags are set
+			fileType := viper.GetString("new-filetype")
+			expiresIn := viper.GetInt("new-expiresIn")
+			encrypt := viper.GetBool("new-encrypt")
+			redactSecrets := viper.GetBool("new-redact")
+			server, err := currentServer()
+			if err != nil {
+				exitWithError(err)
+			}
+			if !cmd.Flags().Changed("filetype") {
+				if server.FileType != "" {
+					fileType = server.FileType
+				}
+				if detected := filetype.Detect(filePath, content); detected != "" {
+					fileType = detected
+				}
+			}
+			if server.Expires != 0 && !cmd.Flags().Changed("expires") {
+				expiresIn = server.Expires
+			}
+			rule, ok, err := matchRule(filePath, fileType)
+			if err != nil {
+				exitWithError(invalidError(err))
+			}
+			if ok {
+				if rule.FileType != "" && !cmd.Flags().Changed("filetype") {
+					fileType = rule.FileType
+				}
+				if rule.Expires != 0 && !cmd.Flags().Changed("expires") {
+					expiresIn = rule.Expires
+				}
+				if rule.Redact && !cmd.Flags().Changed("redact") {
+					redactSecrets = true
+				}
+			}
+
+			if viper.GetBool("new-detectOnly") {
+				err := printResult(detectResult{FileType: fileType}, func(w io.Writer) error {
+					_, err := fmt.Fprintln(w, fileType)
+					return err
+				})
+				if err != nil {
+					exitWithError(err)
+				}
+				return
+			}
+
+			// Read password to encrypt with if asked
+			var password []byte
+			passFile := viper.GetString("new-passwordFile")
+			if viper.GetBool("new-password") || passFile != "" {
+				if password, err = readPassword(passFile, true); err != nil {
+					exitWithError(err)
+				}
+			}
+
+			// Look up recipients to encrypt to
+			var recipients []*api.Recipient
+			if names := viper.GetStringSlice("new-recipient"); len(names) > 0 {
+				_, entries := loadRecipients()
+				for _, name := range names {
+					r, err := keys.Resolve(entries, name)
+					if err != nil {
+						exitWithError(invalidError(err))
+					}
+					recipients = append(recipients, r)
+				}
+			}
+
+			if ok && rule.Encrypt && password == nil && recipients == nil {
+				encrypt = true
+			}
+
+			// Replace secrets in text content before it leaves the machine
+			if redactSecrets && !api.IsBinary(content) {
+				var n int
+				switch content, n = redact.Content(content); {
+				case n == 1:
+					notef("Redacted 1 secret")
+				case n > 1:
+					notef("Redacted %d secrets", n)
+				}
 			}
 
 			// Send request and print response
-			resp, err := api.CreatePaste()
+			ctx, cancel := requestContext(cmd)
+			defer cancel()
+			client := newClient()
+			resp, err := client.CreatePaste(ctx, api.CreateRequest{
+				Content:    content,
+				FileType:   fileType,
+				ExpiresIn:  expiresIn,
+				Encrypt:    encrypt,
+				Password:   password,
+				Recipients: recipients,
+			})
 			if err != nil {
-				fmt.Println(err)
-				os.Exit(1)
+				exitWithError(err)
 			}
+			recordPaste(client, resp, fileType, filePath, name)
 
-			fmt.Printf("uuid:      \t%s\n", resp["uuid"])
-			fmt.Printf("accessKey: \t%s\n", resp["accessKey"])
-			fmt.Printf("expiresAt: \t%s\n", resp["expiresAt"])
-			fmt.Printf("url:       \t%s\n", resp["url"])
+			result := pasteResult{
+				UUID:      resp.UUID,
+				AccessKey: resp.AccessKey,
+				FileType:  fileType,
+				ExpiresAt: resp.ExpiresAt,
+				URL:       resp.URL.String(),
+				Name:      name,
+			}
+			err = printResult(result, func(w io.Writer) error {
+				_, err := fmt.Fprintf(
+					w,
+					"uuid:      \t%s\naccessKey: \t%s\nexpiresAt: \t%s\nurl:       \t%s\n",
+					result.UUID,
+					result.AccessKey,
+					result.ExpiresAt.Format(time.RFC3339),
+					result.URL,
+				)
+				return err
+			})
+			if err != nil {
+				exitWithError(err)
+			}
 		},
 	}
 )
@@ -94,7 +237,7 @@ func init() {
 		"filetype",
 		"t",
 		"plaintext",
-		"Filetype of paste",
+		"Filetype of paste (default is detected)",
 	)
 	newCmd.Flags().IntVarP(
 		&newExpiresIn,
@@ -103,11 +246,70 @@ func init() {
 		14,
 		"Number of days before paste expries (1-30)",
 	)
+	newCmd.Flags().BoolVar(
+		&newEncrypt,
+		"encrypt",
+		false,
+		"Encrypt the paste with a random key kept in the URL fragment",
+	)
+	newCmd.Flags().BoolVar(
+		&newPassword,
+		"password",
+		false,
+		"Encrypt the paste with a password (prompted or from $"+passwordEnv+")",
+	)
+	newCmd.Flags().StringVar(
+		&newPassFile,
+		"password-file",
+		"",
+		"Encrypt the paste with the password in the file given",
+	)
+	newCmd.Flags().StringSliceVarP(
+		&newRecipient,
+		"recipient",
+		"r",
+		nil,
+		"Encrypt the paste to a recipient name or public key (repeatable)",
+	)
+	newCmd.Flags().BoolVar(
+		&newRedact,
+		"redact",
+		false,
+		"Replace secrets such as passwords, tokens and private keys before upload",
+	)
+	newCmd.Flags().BoolVar(
+		&newDetectOnly,
+		"detect-only",
+		false,
+		"Print the filetype the paste would be created with and exit",
+	)
+	newCmd.Flags().StringVar(
+		&newName,
+		"name",
+		"",
+		"Name to refer to the paste by as @name, taken from any paste with it",
+	)
+	newCmd.MarkFlagsMutuallyExclusive("encrypt", "password", "recipient")
+	newCmd.MarkFlagsMutuallyExclusive("encrypt", "password-file", "recipient")
 
 	viper.BindPFlag("new-file", newCmd.Flags().Lookup("file"))
 	viper.BindPFlag("new-filetype", newCmd.Flags().Lookup("filetype"))
 	viper.BindPFlag("new-expiresIn", newCmd.Flags().Lookup("expires"))
+	viper.BindPFlag("new-encrypt", newCmd.Flags().Lookup("encrypt"))
+	viper.BindPFlag("new-password", newCmd.Flags().Lookup("password"))
+	viper.BindPFlag("new-passwordFile", newCmd.Flags().Lookup("password-file"))
+	viper.BindPFlag("new-recipient", newCmd.Flags().Lookup("recipient"))
+	viper.BindPFlag("new-redact", newCmd.Flags().Lookup("redact"))
+	viper.BindPFlag("new-detectOnly", newCmd.Flags().Lookup("detect-only"))
+	viper.BindPFlag("new-name", newCmd.Flags().Lookup("name"))
 	viper.SetDefault("new-file", "")
 	viper.SetDefault("new-filetype", "plaintext")
 	viper.SetDefault("new-expiresIn", 14)
+	viper.SetDefault("new-encrypt", false)
+	viper.SetDefault("new-password", false)
+	viper.SetDefault("new-passwordFile", "")
+	viper.SetDefault("new-recipient", []string{})
+	viper.SetDefault("new-redact", false)
+	viper.SetDefault("new-detectOnly", false)
+	viper.SetDefault("new-name", "")
 }
@@ -0,0 +1,229 @@
+/*
+Copyright © 2022 Harry Law <hrryslw@pm.me>
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Output formats besides Go templates
+const (
+	outputText = "text"
+	outputJson = "json"
+	outputYaml = "yaml"
+)
+
+// checkOutputFormat checks the output format is text, json, yaml or a Go
+// template
+func checkOutputFormat(format string) error {
+	switch format {
+	case outputText, outputJson, outputYaml:
+		return nil
+	}
+	if !strings.Contains(format, "{{") {
+		return fmt.Errorf(
+			"Invalid output format %q (one of text, json, yaml or a Go template)",
+			format,
+		)
+	}
+	_, err := template.New("output").Parse(format)
+	return err
+}
+
+// notef prints an informational message to stderr unless quiet
+func notef(format string, a ...interface{}) {
+	if !quiet {
+		fmt.Fprintf(os.Stderr, format+"\n", a...)
+	}
+}
+
+// warnf prints a warning to stderr unless quiet
+func warnf(format string, a ...interface{}) {
+	if !quiet {
+		fmt.Fprintf(os.Stderr, "Warning: "+format+"\n", a...)
+	}
+}
+
+// machineOutput reports whether the output format is for scripts rather than
+// people
+func machineOutput() bool {
+	return outputFormat != outputText
+}
+
+// printResult writes the result of a command to stdout in the output format,
+// text writes it for people. JSON and YAML use the json tags of the result
+// and templates its Go field names.
+func printResult(result interface{}, text func(w io.Writer) error) error {
+	switch outputFormat {
+	case outputText:
+		return text(os.Stdout)
+	case outputJson:
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(result)
+	case outputYaml:
+		// Go through JSON so YAML has the same field names, parsing into a
+		// node keeps the order of the fields
+		b, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		var n yaml.Node
+		if err := yaml.Unmarshal(b, &n); err != nil {
+			return err
+		}
+		clearStyle(&n)
+		enc := yaml.NewEncoder(os.Stdout)
+		enc.SetIndent(2)
+		if err := enc.Encode(&n); err != nil {
+			return err
+		}
+		return enc.Close()
+	}
+
+	tmpl, err := template.New("output").Parse(outputFormat)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, result); err != nil {
+		return err
+	}
+	if buf.Len() > 0 && !bytes.HasSuffix(buf.Bytes(), []byte("\n")) {
+		buf.WriteByte('\n')
+	}
+	_, err = os.Stdout.Write(buf.Bytes())
+	return err
+}
+
+// clearStyle resets the flow and quoting styles of JSON parsed as YAML so it
+// is written in block style
+func clearStyle(n *yaml.Node) {
+	n.Style = 0
+	for _, c := range n.Content {
+		clearStyle(c)
+	}
+}
+
+// The results of commands written in the json, yaml and template output
+// formats. The json tags are the field names in JSON and YAML and the Go
+// field names are used in templates, neither should change once released.
+
+// pasteResult is the result of new and update
+type pasteResult struct {
+	UUID      string    `json:"uuid"`
+	AccessKey string    `json:"accessKey,omitempty"`
+	FileType  string    `json:"filetype,omitempty"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	URL       string    `json:"url"`
+	Name      string    `json:"name,omitempty"`
+}
+
+// getResult is the result of get, binary content is base64 encoded
+type getResult struct {
+	UUID      string `json:"uuid"`
+	FileType  string `json:"filetype"`
+	ExpiresAt string `json:"expiresAt"`
+	Encoding  string `json:"encoding"`
+	Content   string `json:"content"`
+}
+
+// deleteResult is the result of delete
+type deleteResult struct {
+	UUID    string `json:"uuid"`
+	Message string `json:"message"`
+}
+
+// detectResult is the result of new with the detect-only flag
+type detectResult struct {
+	FileType string `json:"filetype"`
+}
+
+// keygenResult is the result of keygen
+type keygenResult struct {
+	Identity  string `json:"identity"`
+	PublicKey string `json:"publicKey"`
+}
+
+// recipientResult is a recipient listed by recipients list
+type recipientResult struct {
+	Name      string `json:"name"`
+	Recipient string `json:"recipient"`
+}
+
+// serverResult is a server listed by server list
+type serverResult struct {
+	Name     string `json:"name"`
+	URL      string `json:"url"`
+	Default  bool   `json:"default"`
+	FileType string `json:"filetype,omitempty"`
+	Expires  int    `json:"expires,omitempty"`
+}
+
+// keystoreResult is an access key listed by keystore list, the access keys
+// themselves are never listed
+type keystoreResult struct {
+	UUID   string `json:"uuid"`
+	Server string `json:"server"`
+}
+
+// aliasResult is a named paste listed by alias list
+type aliasResult struct {
+	Name   string `json:"name"`
+	UUID   string `json:"uuid"`
+	Server string `json:"server"`
+}
+
+// validateResult is a config file checked by config validate, the problems
+// found are printed to stderr
+type validateResult struct {
+	File     string `json:"file"`
+	Valid    bool   `json:"valid"`
+	Errors   int    `json:"errors"`
+	Warnings int    `json:"warnings"`
+}
+
+// configResult is a setting listed by config view, the origin is always
+// included
+type configResult struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Origin string `json:"origin"`
+}
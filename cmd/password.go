@@ -0,0 +1,130 @@
+/*
+Copyright © 2022 Harry Law <hrryslw@pm.me>
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/h5law/paste-cli/utils"
+)
+
+// Environment variables secrets can be read from
+const (
+	passwordEnv   = "PASTE_PASSWORD"
+	passphraseEnv = "PASTE_KEYSTORE_PASSPHRASE"
+)
+
+// secretSource describes where a secret is read from
+type secretSource struct {
+	// name of the secret used in prompts and errors
+	name string
+	// file to read the secret from and the flag used to set it
+	file     string
+	fileFlag string
+	// env is the environment variable the secret can be set in
+	env string
+}
+
+// readPassword returns the password used to encrypt or decrypt a paste read
+// from the file given, the PASTE_PASSWORD environment variable or a prompt on
+// the terminal in that order, confirm asks for the password twice on prompt
+func readPassword(file string, confirm bool) ([]byte, error) {
+	return readSecret(secretSource{
+		name:     "Password",
+		file:     file,
+		fileFlag: "--password-file",
+		env:      passwordEnv,
+	}, confirm)
+}
+
+// readSecret reads a secret from the file of the source, its environment
+// variable or a prompt on the terminal in that order, confirm asks for the
+// secret twice on prompt
+func readSecret(src secretSource, confirm bool) ([]byte, error) {
+	if src.file != "" {
+		b, err := ioutil.ReadFile(src.file)
+		if err != nil {
+			return nil, err
+		}
+		// Only the first line is used so files ending in a newline work
+		secret := bytes.SplitN(b, []byte("\n"), 2)[0]
+		return checkSecret(src, bytes.TrimSuffix(secret, []byte("\r")))
+	}
+	if env, ok := os.LookupEnv(src.env); ok {
+		return checkSecret(src, []byte(env))
+	}
+
+	secret, err := utils.PromptSecret(src.name + ": ")
+	if err != nil {
+		hint := src.env
+		if src.fileFlag != "" {
+			hint = src.fileFlag + " or " + src.env
+		}
+		err = fmt.Errorf(
+			"Unable to read %s, use %s: %w",
+			strings.ToLower(src.name),
+			hint,
+			err,
+		)
+		// A missing secret needed to unlock something is an authentication
+		// failure, a missing new one is a usage error
+		if confirm {
+			return nil, usageError(err)
+		}
+		return nil, &codedError{err, exitForbidden}
+	}
+	if len(secret) == 0 {
+		return checkSecret(src, secret)
+	}
+	if confirm {
+		again, err := utils.PromptSecret("Confirm " + strings.ToLower(src.name) + ": ")
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(secret, again) {
+			return nil, invalidError(fmt.Errorf("%ss do not match", src.name))
+		}
+	}
+
+	return secret, nil
+}
+
+// checkSecret returns an invalid value error for an empty secret
+func checkSecret(src secretSource, secret []byte) ([]byte, error) {
+	if len(secret) == 0 {
+		return nil, invalidError(fmt.Errorf("%s must not be empty", src.name))
+	}
+	return secret, nil
+}
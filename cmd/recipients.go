@@ -0,0 +1,147 @@
+/*
+Copyright © 2022 Harry Law <hrryslw@pm.me>
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/h5law/paste-cli/api"
+	"github.com/h5law/paste-cli/keys"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// recipientsCmd represents the recipients command
+var (
+	recipientsCmd = &cobra.Command{
+		Use:   "recipients",
+		Short: "Manage recipients of encrypted pastes",
+		Long: `Manage the named public keys pastes can be encrypted to with the
+recipient flag of the new command.`,
+	}
+
+	recipientsAddCmd = &cobra.Command{
+		Use:   "add <name> <public key>",
+		Short: "Add a recipient",
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			name, key := args[0], args[1]
+			if err := keys.CheckName(name); err != nil {
+				exitWithError(invalidError(err))
+			}
+			r, err := api.ParseRecipient(key)
+			if err != nil {
+				exitWithError(invalidError(err))
+			}
+
+			path, entries := loadRecipients()
+			for _, e := range entries {
+				if e.Name == name {
+					exitWithError(invalidError(fmt.Errorf("Recipient already exists: %s", name)))
+				}
+			}
+			entries = append(entries, keys.Entry{Name: name, Recipient: r})
+			if err := keys.SaveRecipients(path, entries); err != nil {
+				exitWithError(err)
+			}
+		},
+	}
+
+	recipientsListCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List recipients",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			_, entries := loadRecipients()
+			results := make([]recipientResult, len(entries))
+			for i, e := range entries {
+				results[i] = recipientResult{Name: e.Name, Recipient: e.Recipient.String()}
+			}
+			err := printResult(results, func(out io.Writer) error {
+				w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+				for _, r := range results {
+					fmt.Fprintf(w, "%s\t%s\n", r.Name, r.Recipient)
+				}
+				return w.Flush()
+			})
+			if err != nil {
+				exitWithError(err)
+			}
+		},
+	}
+
+	recipientsRemoveCmd = &cobra.Command{
+		Use:   "remove <name>",
+		Short: "Remove a recipient",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			path, entries := loadRecipients()
+			kept := entries[:0]
+			for _, e := range entries {
+				if e.Name != args[0] {
+					kept = append(kept, e)
+				}
+			}
+			if len(kept) == len(entries) {
+				exitWithError(invalidError(fmt.Errorf("Unknown recipient: %s", args[0])))
+			}
+			if err := keys.SaveRecipients(path, kept); err != nil {
+				exitWithError(err)
+			}
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(recipientsCmd)
+	recipientsCmd.AddCommand(recipientsAddCmd)
+	recipientsCmd.AddCommand(recipientsListCmd)
+	recipientsCmd.AddCommand(recipientsRemoveCmd)
+}
+
+// loadRecipients returns the path of the recipients file set in the config
+// or the default one and the recipients in it
+func loadRecipients() (string, []keys.Entry) {
+	path := viper.GetString("recipients-file")
+	if path == "" {
+		var err error
+		if path, err = keys.DefaultRecipientsPath(); err != nil {
+			exitWithError(err)
+		}
+	}
+	entries, err := keys.LoadRecipients(path)
+	if err != nil {
+		exitWithError(err)
+	}
+	return path, entries
+}
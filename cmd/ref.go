@@ -0,0 +1,191 @@
+/*
+Copyright © 2022 Harry Law <hrryslw@pm.me>
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/h5law/paste-cli/api"
+	"github.com/h5law/paste-cli/ledger"
+	"github.com/spf13/viper"
+)
+
+// aliasName matches the names that can be given to pastes, last is kept for
+// the most recent paste
+var aliasName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// checkAliasName checks a name can be given to a paste
+func checkAliasName(name string) error {
+	if !aliasName.MatchString(name) || name == "last" {
+		return invalidError(fmt.Errorf(
+			"Invalid paste name %q, use letters, digits, '.', '_' and '-' (not last)",
+			name,
+		))
+	}
+	return nil
+}
+
+// pasteRef returns the paste given as the argument of the command or with
+// its uuid flag, either a UUID, a paste URL or a reference to the local
+// history such as @last, @~2 or @name
+func pasteRef(args []string, prefix string) api.Ref {
+	ref := viper.GetString(prefix + "-uuid")
+	switch {
+	case len(args) > 0 && ref != "":
+		exitWithError(usageError(errors.New(
+			"Give the paste as an argument or with --uuid, not both",
+		)))
+	case len(args) > 0:
+		ref = args[0]
+	case ref == "":
+		exitWithError(usageError(errors.New(
+			"No paste given, pass its UUID, URL or @ref as an argument",
+		)))
+	}
+	return parseRef(ref)
+}
+
+// parseRef parses a paste UUID, URL or reference to the local history
+func parseRef(ref string) api.Ref {
+	if strings.HasPrefix(ref, "@") {
+		e, err := historyEntry(ref)
+		if err != nil {
+			exitWithError(err)
+		}
+		key, err := historyKey(e)
+		if err != nil {
+			exitWithError(err)
+		}
+		return api.Ref{Server: e.Server, UUID: e.UUID, Key: key}
+	}
+	r, err := api.ParseRef(ref)
+	if err != nil {
+		exitWithError(invalidError(err))
+	}
+	return r
+}
+
+// historyEntry returns the ledger entry of a reference to the local history,
+// @last for the most recent paste, @~n for the one n before it or @name for
+// a paste given a name
+func historyEntry(ref string) (ledger.Entry, error) {
+	l, err := openLedger()
+	if err != nil {
+		return ledger.Entry{}, err
+	}
+
+	var e ledger.Entry
+	var found bool
+	name := strings.TrimPrefix(ref, "@")
+	switch {
+	case name == "last":
+		e, found, err = l.Recent(0)
+	case strings.HasPrefix(name, "~"):
+		n, convErr := strconv.Atoi(strings.TrimPrefix(name, "~"))
+		if convErr != nil || n < 0 {
+			return ledger.Entry{}, invalidError(fmt.Errorf(
+				"Invalid paste reference %q, expected @~<number>",
+				ref,
+			))
+		}
+		e, found, err = l.Recent(n)
+	default:
+		e, found, err = l.Named(name)
+	}
+	if err != nil {
+		return ledger.Entry{}, err
+	}
+	if !found {
+		return ledger.Entry{}, invalidError(
+			fmt.Errorf("No paste %s in the local history", ref),
+		)
+	}
+	return e, nil
+}
+
+// historyKey returns the key a paste in the local history was encrypted with
+// from the ledger or keystore, nil if it isn't encrypted under a key
+func historyKey(e ledger.Entry) ([]byte, error) {
+	if !e.Encrypted {
+		return nil, nil
+	}
+	key := e.Key
+	if key == "" {
+		ks, err := openKeystore()
+		if err != nil {
+			return nil, err
+		}
+		if ks != nil {
+			if key, _, err = ks.GetKey(e.Server, e.UUID); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if key == "" {
+		return nil, nil
+	}
+	return api.DecodeKey(key)
+}
+
+// refClient creates an API client for the server of the paste, the server
+// in a paste URL is used over the one chosen with the server flag or config
+// so links from any instance work
+func refClient(ref api.Ref) *api.Client {
+	if ref.Server == "" {
+		return newClient()
+	}
+
+	// Keep the headers and TLS settings of a server with the same URL,
+	// preferring the current server
+	sameUrl := func(p serverProfile) bool {
+		return strings.TrimRight(p.URL, "/") == ref.Server
+	}
+	if current, err := currentServer(); err == nil && sameUrl(current) {
+		return serverClient(current)
+	}
+	profiles := serverProfiles()
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if sameUrl(profiles[name]) {
+			return serverClient(profiles[name])
+		}
+	}
+	return serverClient(serverProfile{URL: ref.Server})
+}
@@ -31,16 +31,30 @@ POSSIBILITY OF SUCH DAMAGE.
 package cmd
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
-	"github.com/h5law/paste-cli/utils"
+	"github.com/h5law/paste-cli/api"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
 var (
-	cfgFile string
+	cfgFile      string
+	timeout      time.Duration
+	outputFormat string
+	quiet        bool
+
+	// envKeyReplacer maps config keys to the environment variables setting
+	// them after the PASTE_ prefix, e.g. retry.max-attempts is read from
+	// PASTE_RETRY_MAX_ATTEMPTS
+	envKeyReplacer = strings.NewReplacer(".", "_", "-", "_")
 
 	// rootCmd represents the base command when called without any subcommands
 	rootCmd = &cobra.Command{
@@ -48,45 +62,174 @@ var (
 		Short: "Interact with a paste-server instance",
 		Long: `The paste CLI tool allows for the interaction with a paste-server
 instance either a self hosted instance or the by default the hosted server.`,
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			quiet = viper.GetBool("quiet")
+			outputFormat = viper.GetString("output")
+			if err := checkOutputFormat(outputFormat); err != nil {
+				exitWithError(invalidError(err))
+			}
+		},
 	}
 )
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
-		os.Exit(1)
+	// Cancel in-flight requests on the first interrupt, a second interrupt
+	// falls back to the default behaviour of killing the process
+	ctx, stop := signal.NotifyContext(
+		context.Background(),
+		os.Interrupt,
+		syscall.SIGTERM,
+	)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	// Cobra has already printed errors in flags and arguments to stderr
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
+		os.Exit(exitUsage)
 	}
 }
 
 func init() {
 	cobra.OnInitialize(initConfig)
 
-	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.paste.yaml)")
+	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file to use instead of the system, user and project files")
+	rootCmd.PersistentFlags().DurationVar(
+		&timeout,
+		"timeout",
+		30*time.Second,
+		"Time to wait for the paste-server to respond (0 for no limit)",
+	)
+
+	// No short flag as -o is the output file of get
+	rootCmd.PersistentFlags().StringVar(
+		&outputFormat,
+		"output",
+		outputText,
+		"Output format: text, json, yaml or a Go template such as '{{.URL}}'",
+	)
+
+	rootCmd.PersistentFlags().BoolVarP(
+		&quiet,
+		"quiet",
+		"q",
+		false,
+		"Only print results and errors, no messages or warnings",
+	)
+
+	viper.BindPFlag("timeout", rootCmd.PersistentFlags().Lookup("timeout"))
+	viper.BindPFlag("output", rootCmd.PersistentFlags().Lookup("output"))
+	viper.BindPFlag("quiet", rootCmd.PersistentFlags().Lookup("quiet"))
+	viper.SetDefault("timeout", 30*time.Second)
+	viper.SetDefault("output", outputText)
+	viper.SetDefault("quiet", false)
+
+	retry := api.DefaultRetryPolicy()
+	viper.SetDefault("retry.max-attempts", retry.MaxAttempts)
+	viper.SetDefault("retry.initial-backoff", retry.InitialBackoff)
+	viper.SetDefault("retry.max-backoff", retry.MaxBackoff)
+	viper.SetDefault("retry.create", retry.RetryCreate)
 }
 
-// initConfig reads in config file and ENV variables if set.
-func initConfig() {
-	if cfgFile != "" {
-		// Use config file from the flag.
-		viper.SetConfigFile(cfgFile)
-	} else {
-		// Find home directory.
-		home, err := os.UserHomeDir()
-		cobra.CheckErr(err)
-
-		// Search config in home directory with name ".paste" (without extension).
-		viper.AddConfigPath(home)
-		viper.SetConfigType("yaml")
-		viper.SetConfigName(".paste")
+// newClient creates an API client for the paste-server chosen with the server
+// flag or set in the config
+func newClient() *api.Client {
+	server, err := currentServer()
+	if err != nil {
+		exitWithError(err)
+	}
+	return serverClient(server)
+}
+
+// serverClient creates an API client for the server given
+func serverClient(server serverProfile) *api.Client {
+	httpClient, err := server.httpClient()
+	if err != nil {
+		exitWithError(err)
 	}
 
+	opts := []api.Option{
+		api.WithHTTPClient(httpClient),
+		api.WithRetryPolicy(api.RetryPolicy{
+			MaxAttempts:    viper.GetInt("retry.max-attempts"),
+			InitialBackoff: viper.GetDuration("retry.initial-backoff"),
+			MaxBackoff:     viper.GetDuration("retry.max-backoff"),
+			RetryCreate:    viper.GetBool("retry.create"),
+		}),
+	}
+	for key, value := range server.Headers {
+		opts = append(opts, api.WithHeader(key, value))
+	}
+	return api.NewClient(server.URL, opts...)
+}
+
+// requestContext returns the context of the command limited by the timeout
+// set in the flags or config
+func requestContext(cmd *cobra.Command) (context.Context, context.CancelFunc) {
+	if timeout := viper.GetDuration("timeout"); timeout > 0 {
+		return context.WithTimeout(cmd.Context(), timeout)
+	}
+	return context.WithCancel(cmd.Context())
+}
+
+// initConfig merges the config files found and reads in environment
+// variables prefixed with PASTE_, flags take precedence over environment
+// variables which take precedence over the config files
+func initConfig() {
+	viper.SetEnvPrefix("PASTE")
+	viper.SetEnvKeyReplacer(envKeyReplacer)
 	viper.AutomaticEnv() // read in environment variables that match
 
-	// If a config file is found, read it in.
-	config := viper.ConfigFileUsed()
-	exists, _ := utils.FileExists(config)
-	if err := viper.ReadInConfig(); err != nil && exists {
-		fmt.Fprintln(os.Stderr, "Error reading config file: ", err)
+	layers, err := findConfigLayers()
+	if err != nil {
+		exitWithError(err)
+	}
+
+	// Warnings are printed once the config is merged so quiet set in any
+	// layer applies to them
+	var warnings []string
+	for _, layer := range layers {
+		doc, err := loadConfigDoc(layer.path)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf(
+				"%s, run `paste config validate` for details",
+				err,
+			))
+			continue
+		}
+		if layer.name == "project" {
+			removed := restrictProjectConfig(doc, configDocs)
+			if len(removed) > 0 {
+				warnings = append(warnings, fmt.Sprintf(
+					"ignoring keys not allowed in project config file %s: %s",
+					layer.path,
+					strings.Join(removed, ", "),
+				))
+			}
+		}
+		configDocs = append(configDocs, doc)
+		configLayers = append(configLayers, layer)
+	}
+
+	// Merge config files in order so later layers override earlier ones
+	viper.SetConfigType("yaml")
+	for _, doc := range configDocs {
+		b, err := doc.encode()
+		if err == nil {
+			err = viper.MergeConfig(bytes.NewReader(b))
+		}
+		if err != nil {
+			exitWithError(invalidError(
+				fmt.Errorf("Error reading config file %s: %w", doc.path, err),
+			))
+		}
+	}
+
+	quiet = viper.GetBool("quiet")
+	for _, w := range warnings {
+		warnf("%s", w)
 	}
 }
@@ -0,0 +1,115 @@
+/*
+Copyright © 2022 Harry Law <hrryslw@pm.me>
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// pasteRule sets the defaults of new pastes from files matching a glob or
+// whose content is in a language, the first rule matching a paste is used
+type pasteRule struct {
+	Match    string `mapstructure:"match"`
+	Language string `mapstructure:"language"`
+	FileType string `mapstructure:"filetype"`
+	Expires  int    `mapstructure:"expires"`
+	Encrypt  bool   `mapstructure:"encrypt"`
+	Redact   bool   `mapstructure:"redact"`
+}
+
+// ruleKeys are the keys known in each rule of the rules section
+var ruleKeys = map[string]configKind{
+	"match":    kindGlob,
+	"language": kindString,
+	"filetype": kindString,
+	"expires":  kindInt,
+	"encrypt":  kindBool,
+	"redact":   kindBool,
+}
+
+// loadRules returns the rules in the config
+func loadRules() ([]pasteRule, error) {
+	var rules []pasteRule
+	if err := viper.UnmarshalKey("rules", &rules); err != nil {
+		return nil, fmt.Errorf("Invalid rules in config: %w", err)
+	}
+	return rules, nil
+}
+
+// matches reports whether the rule applies to a paste of the file at path in
+// the language given, path is empty for content read from stdin
+func (r pasteRule) matches(path, language string) bool {
+	if r.Match == "" && r.Language == "" {
+		return false
+	}
+	if r.Language != "" && !strings.EqualFold(r.Language, language) {
+		return false
+	}
+	if r.Match == "" {
+		return true
+	}
+	if path == "" {
+		return false
+	}
+
+	// Patterns without a separator match the file name in any directory,
+	// others match the end of the path
+	if !strings.Contains(r.Match, "/") {
+		ok, _ := filepath.Match(r.Match, filepath.Base(path))
+		return ok
+	}
+	parts := strings.Split(filepath.ToSlash(filepath.Clean(path)), "/")
+	for i := range parts {
+		if ok, _ := filepath.Match(r.Match, strings.Join(parts[i:], "/")); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// matchRule returns the first rule matching a paste of the file at path in
+// the language given
+func matchRule(path, language string) (pasteRule, bool, error) {
+	rules, err := loadRules()
+	if err != nil {
+		return pasteRule{}, false, err
+	}
+	for _, r := range rules {
+		if r.matches(path, language) {
+			return r, true, nil
+		}
+	}
+	return pasteRule{}, false, nil
+}
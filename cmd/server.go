@@ -0,0 +1,362 @@
+/*
+Copyright © 2022 Harry Law <hrryslw@pm.me>
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+package cmd
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// serverEnv is the environment variable selecting the server to use
+const serverEnv = "PASTE_SERVER"
+
+// serverTLS holds the TLS settings of a server profile
+type serverTLS struct {
+	CAFile             string `mapstructure:"ca-file" yaml:"ca-file,omitempty"`
+	CertFile           string `mapstructure:"cert-file" yaml:"cert-file,omitempty"`
+	KeyFile            string `mapstructure:"key-file" yaml:"key-file,omitempty"`
+	InsecureSkipVerify bool   `mapstructure:"insecure-skip-verify" yaml:"insecure-skip-verify,omitempty"`
+}
+
+// serverProfile is a named paste-server in the servers section of the config
+type serverProfile struct {
+	Name     string            `mapstructure:"-" yaml:"-"`
+	URL      string            `mapstructure:"url" yaml:"url"`
+	FileType string            `mapstructure:"filetype" yaml:"filetype,omitempty"`
+	Expires  int               `mapstructure:"expires" yaml:"expires,omitempty"`
+	Headers  map[string]string `mapstructure:"headers" yaml:"headers,omitempty"`
+	TLS      serverTLS         `mapstructure:"tls" yaml:"tls,omitempty"`
+}
+
+// serverCmd represents the server command
+var (
+	serverName string
+
+	srvAddFileType string
+	srvAddExpires  int
+	srvAddHeaders  []string
+	srvAddTLS      serverTLS
+
+	serverCmd = &cobra.Command{
+		Use:   "server",
+		Short: "Manage named paste-servers",
+		Long: `Manage the named paste-servers in the config, each with its own URL,
+default filetype and expiry, headers and TLS settings.
+
+A server is chosen with the server flag or PASTE_SERVER environment variable,
+otherwise the default server is used falling back to the url in the config
+and then the main hosted server.`,
+	}
+
+	serverAddCmd = &cobra.Command{
+		Use:   "add <name> <url>",
+		Short: "Add a server",
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			name, rawUrl := args[0], args[1]
+			if err := checkServerUrl(rawUrl); err != nil {
+				exitWithError(err)
+			}
+			if _, ok := serverProfiles()[name]; ok {
+				exitWithError(invalidError(fmt.Errorf("Server already exists: %s", name)))
+			}
+
+			p := serverProfile{
+				URL:      rawUrl,
+				FileType: srvAddFileType,
+				Expires:  srvAddExpires,
+				TLS:      srvAddTLS,
+			}
+			for _, h := range srvAddHeaders {
+				key, value, ok := strings.Cut(h, "=")
+				if !ok || key == "" {
+					exitWithError(invalidError(
+						fmt.Errorf("Invalid header %q, expected key=value", h),
+					))
+				}
+				if p.Headers == nil {
+					p.Headers = make(map[string]string)
+				}
+				p.Headers[key] = value
+			}
+
+			editConfig(func(doc *configDoc) error {
+				return doc.set(p, "servers", name)
+			})
+		},
+	}
+
+	serverListCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List servers",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			profiles := serverProfiles()
+			names := make([]string, 0, len(profiles))
+			for name := range profiles {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+
+			def := viper.GetString("default-server")
+			results := make([]serverResult, len(names))
+			for i, name := range names {
+				p := profiles[name]
+				results[i] = serverResult{
+					Name:     name,
+					URL:      p.URL,
+					Default:  name == def,
+					FileType: p.FileType,
+					Expires:  p.Expires,
+				}
+			}
+			err := printResult(results, func(out io.Writer) error {
+				w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+				for _, r := range results {
+					marker := " "
+					if r.Default {
+						marker = "*"
+					}
+					fmt.Fprintf(w, "%s %s\t%s\n", marker, r.Name, r.URL)
+				}
+				return w.Flush()
+			})
+			if err != nil {
+				exitWithError(err)
+			}
+		},
+	}
+
+	serverRemoveCmd = &cobra.Command{
+		Use:   "remove <name>",
+		Short: "Remove a server",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			editConfig(func(doc *configDoc) error {
+				if !doc.unset("servers", args[0]) {
+					return invalidError(fmt.Errorf("Unknown server: %s", args[0]))
+				}
+				if viper.GetString("default-server") == args[0] {
+					doc.unset("default-server")
+				}
+				return nil
+			})
+		},
+	}
+
+	serverDefaultCmd = &cobra.Command{
+		Use:   "default <name>",
+		Short: "Set the default server",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if _, ok := serverProfiles()[args[0]]; !ok {
+				exitWithError(invalidError(fmt.Errorf("Unknown server: %s", args[0])))
+			}
+			editConfig(func(doc *configDoc) error {
+				return doc.set(args[0], "default-server")
+			})
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(serverCmd)
+	serverCmd.AddCommand(serverAddCmd)
+	serverCmd.AddCommand(serverListCmd)
+	serverCmd.AddCommand(serverRemoveCmd)
+	serverCmd.AddCommand(serverDefaultCmd)
+
+	rootCmd.PersistentFlags().StringVarP(
+		&serverName,
+		"server",
+		"s",
+		"",
+		"Name or URL of the paste-server to use (default is the default server)",
+	)
+	viper.BindPFlag("server", rootCmd.PersistentFlags().Lookup("server"))
+	viper.BindEnv("server", serverEnv)
+	viper.SetDefault("server", "")
+	viper.SetDefault("default-server", "")
+
+	serverAddCmd.Flags().StringVarP(
+		&srvAddFileType,
+		"filetype",
+		"t",
+		"",
+		"Default filetype of pastes on the server",
+	)
+	serverAddCmd.Flags().IntVarP(
+		&srvAddExpires,
+		"expires",
+		"e",
+		0,
+		"Default number of days before pastes on the server expire",
+	)
+	serverAddCmd.Flags().StringArrayVarP(
+		&srvAddHeaders,
+		"header",
+		"H",
+		nil,
+		"Header sent with every request as key=value (repeatable)",
+	)
+	serverAddCmd.Flags().StringVar(
+		&srvAddTLS.CAFile,
+		"ca-file",
+		"",
+		"CA certificates to verify the server with",
+	)
+	serverAddCmd.Flags().StringVar(
+		&srvAddTLS.CertFile,
+		"cert-file",
+		"",
+		"Client certificate to authenticate with",
+	)
+	serverAddCmd.Flags().StringVar(
+		&srvAddTLS.KeyFile,
+		"key-file",
+		"",
+		"Key of the client certificate",
+	)
+	serverAddCmd.Flags().BoolVar(
+		&srvAddTLS.InsecureSkipVerify,
+		"insecure-skip-verify",
+		false,
+		"Don't verify the server certificate",
+	)
+	serverAddCmd.MarkFlagsRequiredTogether("cert-file", "key-file")
+}
+
+// serverProfiles returns the servers in the config keyed by name
+func serverProfiles() map[string]serverProfile {
+	profiles := make(map[string]serverProfile)
+	if err := viper.UnmarshalKey("servers", &profiles); err != nil {
+		exitWithError(fmt.Errorf("Invalid servers in config: %w", err))
+	}
+	for name, p := range profiles {
+		p.Name = name
+		profiles[name] = p
+	}
+	return profiles
+}
+
+// currentServer returns the server chosen with the server flag or
+// environment variable, the default server or the legacy url key in that
+// order, the server chosen can also be a URL
+func currentServer() (serverProfile, error) {
+	name := viper.GetString("server")
+	if name == "" {
+		name = viper.GetString("default-server")
+	}
+	if name == "" {
+		return serverProfile{URL: viper.GetString("url")}, nil
+	}
+	if p, ok := serverProfiles()[name]; ok {
+		return p, nil
+	}
+	if strings.Contains(name, "://") {
+		return serverProfile{URL: name}, checkServerUrl(name)
+	}
+	return serverProfile{}, invalidError(fmt.Errorf("Unknown server: %s", name))
+}
+
+// httpClient returns an http.Client using the TLS settings of the profile
+func (p serverProfile) httpClient() (*http.Client, error) {
+	if p.TLS == (serverTLS{}) {
+		return http.DefaultClient, nil
+	}
+	config := &tls.Config{InsecureSkipVerify: p.TLS.InsecureSkipVerify}
+	if p.TLS.CAFile != "" {
+		pem, err := ioutil.ReadFile(p.TLS.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, invalidError(
+				fmt.Errorf("No certificates found in %s", p.TLS.CAFile),
+			)
+		}
+	}
+	if p.TLS.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(p.TLS.CertFile, p.TLS.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = config
+	return &http.Client{Transport: transport}, nil
+}
+
+// checkServerUrl checks a server URL is an absolute http(s) URL
+func checkServerUrl(rawUrl string) error {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return invalidError(err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return invalidError(
+			errors.New("Invalid server URL, expected http(s)://host[:port]"),
+		)
+	}
+	return nil
+}
+
+// editConfig applies fn to the config file and saves it
+func editConfig(fn func(*configDoc) error) {
+	path, err := configFilePath()
+	if err != nil {
+		exitWithError(err)
+	}
+	doc, err := loadConfigDoc(path)
+	if err != nil {
+		exitWithError(err)
+	}
+	if err := fn(doc); err != nil {
+		exitWithError(err)
+	}
+	if err := doc.save(); err != nil {
+		exitWithError(err)
+	}
+}
@@ -0,0 +1,154 @@
+/*
+Copyright © 2022 Harry Law <hrryslw@pm.me>
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"syscall"
+	"unicode/utf8"
+
+	"github.com/h5law/paste-cli/highlight"
+	"github.com/h5law/paste-cli/utils"
+	"github.com/spf13/viper"
+	"golang.org/x/term"
+)
+
+// colorOutput reports whether output to stdout is colored given the value of
+// a color flag, auto colors output to a terminal unless NO_COLOR is set
+func colorOutput(when string) (bool, error) {
+	switch when {
+	case "always":
+		return true, nil
+	case "never":
+		return false, nil
+	case "auto":
+		return utils.IsOutputToTerminal() &&
+			os.Getenv("NO_COLOR") == "" &&
+			os.Getenv("TERM") != "dumb", nil
+	}
+	return false, invalidError(
+		fmt.Errorf("Invalid color %q (one of auto, always, never)", when),
+	)
+}
+
+// colorMode returns the colors supported by the terminal, truecolor is only
+// used when the terminal says it supports it in COLORTERM
+func colorMode() highlight.ColorMode {
+	switch strings.ToLower(os.Getenv("COLORTERM")) {
+	case "truecolor", "24bit":
+		return highlight.TrueColor
+	}
+	return highlight.Color256
+}
+
+// defaultPager is used when neither the pager config key nor $PAGER is set
+const defaultPager = "less -R"
+
+// pagerCommand returns the pager set in the config, $PAGER or the default
+// pager in that order. The pager is run by the shell so it is never read from
+// a project config file, see projectConfigKeys
+func pagerCommand() string {
+	if pager := viper.GetString("pager"); pager != "" {
+		return pager
+	}
+	if pager := os.Getenv("PAGER"); pager != "" {
+		return pager
+	}
+	return defaultPager
+}
+
+// writePaged writes out to stdout, through the pager if page is set, stdout
+// is a terminal and out is taller than the terminal
+func writePaged(out []byte, page bool) error {
+	pager := pagerCommand()
+	if !page || pager == "cat" || !utils.IsOutputToTerminal() {
+		_, err := os.Stdout.Write(out)
+		return err
+	}
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || screenLines(out, width) < height {
+		_, err := os.Stdout.Write(out)
+		return err
+	}
+
+	cmd := exec.Command("sh", "-c", pager)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	// Have less show colors and quit when it has less than a screen to show
+	// unless told otherwise like git does
+	cmd.Env = os.Environ()
+	if _, ok := os.LookupEnv("LESS"); !ok {
+		cmd.Env = append(cmd.Env, "LESS=FRX")
+	}
+	if _, ok := os.LookupEnv("LV"); !ok {
+		cmd.Env = append(cmd.Env, "LV=-c")
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("Unable to start pager %q: %w", pager, err)
+	}
+
+	// The pager closes its input when quit before reaching the end
+	_, err = stdin.Write(out)
+	stdin.Close()
+	if err != nil && !errors.Is(err, syscall.EPIPE) {
+		cmd.Wait()
+		return err
+	}
+	return cmd.Wait()
+}
+
+// ansiEscape matches the escape sequences used to color output
+var ansiEscape = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// screenLines returns how many lines of a terminal width columns wide out
+// takes up once long lines wrap
+func screenLines(out []byte, width int) int {
+	lines := 0
+	for _, line := range bytes.Split(bytes.TrimSuffix(out, []byte("\n")), []byte("\n")) {
+		n := utf8.RuneCount(ansiEscape.ReplaceAll(line, nil))
+		if width <= 0 || n <= width {
+			lines++
+			continue
+		}
+		lines += (n + width - 1) / width
+	}
+	return lines
+}
@@ -31,10 +31,15 @@ POSSIBILITY OF SUCH DAMAGE.
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"os"
+	"io"
+	"time"
 
 	"github.com/h5law/paste-cli/api"
+	"github.com/h5law/paste-cli/keys"
+	"github.com/h5law/paste-cli/ledger"
 	"github.com/h5law/paste-cli/utils"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -45,28 +50,108 @@ var (
 	updFilePath  string
 	updFileType  string
 	updUuid      string
-	updAccessKey string
 	updExpiresIn int
+	updKey       string
+	updPassword  bool
+	updPassFile  string
+	updIdentity  string
+	updRecipient []string
 
 	updateCmd = &cobra.Command{
-		Use:   "update",
+		Use:   "update [uuid|url|@ref]",
 		Short: "Update paste",
-		Long: `Update a paste with the matching UUID automatically extending its time to
-expire by 14 days unless told otherwise.`,
+		Long: `Update a paste with the matching UUID or paste URL automatically extending
+its time to expire by 14 days unless told otherwise. Pastes in the ledger can
+also be given as @last, @~n for the one n before it or @name for a named
+paste.
+
+New content of an encrypted paste is encrypted the same way: under the key
+in the URL fragment or given with the key flag, with its password or to the
+same recipients using your identity. The update is refused if the paste
+can't be unlocked so it is never replaced with plaintext.`,
+		Args: cobra.MaximumNArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
-			if pipe := utils.IsInputFromPipe(); pipe {
-				viper.Set("file", "")
+			ref := pasteRef(args, "upd")
+			key := ref.Key
+			if k := viper.GetString("upd-key"); k != "" {
+				var err error
+				if key, err = api.DecodeKey(k); err != nil {
+					exitWithError(invalidError(err))
+				}
+			}
+
+			// Only read content if piped or a file is given, stdin is
+			// left for the access key if asked
+			var content []byte
+			filePath := viper.GetString("upd-file")
+			pipe := utils.IsInputFromPipe() &&
+				!viper.GetBool("upd-accessKeyStdin")
+			if pipe || filePath != "" {
+				if pipe {
+					filePath = ""
+				}
+				var err error
+				content, err = utils.ReadContent(filePath)
+				if err != nil {
+					exitWithError(invalidError(err))
+				}
 			}
 
-			resp, err := api.UpdatePaste()
+			// Read the access key or look up a stored one
+			client := refClient(ref)
+			uuid := ref.UUID
+			accessKey, err := lookupAccessKey(client, uuid, "upd")
 			if err != nil {
-				fmt.Println(err)
-				os.Exit(1)
+				exitWithError(err)
 			}
 
-			fmt.Printf("uuid:      \t%s\n", resp["uuid"])
-			fmt.Printf("expiresAt: \t%s\n", resp["expiresAt"])
-			fmt.Printf("url:       \t%s\n", resp["url"])
+			ctx, cancel := requestContext(cmd)
+			defer cancel()
+			fileType := viper.GetString("upd-filetype")
+			req := api.UpdateRequest{
+				UUID:      uuid,
+				AccessKey: accessKey,
+				Content:   content,
+				FileType:  fileType,
+				ExpiresIn: viper.GetInt("upd-expiresIn"),
+				Key:       key,
+			}
+			if content != nil {
+				if err := unlockForUpdate(ctx, client, &req); err != nil {
+					exitWithError(err)
+				}
+			}
+			resp, err := client.UpdatePaste(ctx, req)
+			if err != nil {
+				exitWithError(err)
+			}
+			updateLedger(client, uuid, func(e *ledger.Entry) bool {
+				e.ExpiresAt = resp.ExpiresAt
+				if fileType != "" {
+					e.FileType = fileType
+				}
+				return true
+			})
+
+			result := pasteResult{
+				UUID:      resp.UUID,
+				FileType:  fileType,
+				ExpiresAt: resp.ExpiresAt,
+				URL:       resp.URL.String(),
+			}
+			err = printResult(result, func(w io.Writer) error {
+				_, err := fmt.Fprintf(
+					w,
+					"uuid:      \t%s\nexpiresAt: \t%s\nurl:       \t%s\n",
+					result.UUID,
+					result.ExpiresAt.Format(time.RFC3339),
+					result.URL,
+				)
+				return err
+			})
+			if err != nil {
+				exitWithError(err)
+			}
 		},
 	}
 )
@@ -79,17 +164,9 @@ func init() {
 		"uuid",
 		"u",
 		"",
-		"UUID of paste to edit",
-	)
-	updateCmd.MarkFlagRequired("uuid")
-	updateCmd.Flags().StringVarP(
-		&updAccessKey,
-		"access-key",
-		"a",
-		"",
-		"Access key needed to update paste",
+		"UUID, URL or @ref of paste to edit",
 	)
-	updateCmd.MarkFlagRequired("access-key")
+	addAccessKeyFlags(updateCmd, "upd", "update")
 
 	updateCmd.Flags().StringVarP(
 		&updFilePath,
@@ -114,14 +191,103 @@ func init() {
 		"Number of days before paste expries (1-30)",
 	)
 
+	updateCmd.Flags().StringVarP(
+		&updKey,
+		"key",
+		"k",
+		"",
+		"Key to encrypt the new content of an encrypted paste with",
+	)
+	updateCmd.Flags().BoolVar(
+		&updPassword,
+		"password",
+		false,
+		"Encrypt the new content with a password (prompted or from $"+passwordEnv+")",
+	)
+	updateCmd.Flags().StringVar(
+		&updPassFile,
+		"password-file",
+		"",
+		"Encrypt the new content with the password in the file given",
+	)
+	updateCmd.Flags().StringVarP(
+		&updIdentity,
+		"identity",
+		"i",
+		"",
+		"Identity file to unlock a paste encrypted to recipients (default is the identity file)",
+	)
+	updateCmd.Flags().StringSliceVarP(
+		&updRecipient,
+		"recipient",
+		"r",
+		nil,
+		"Encrypt the new content to a recipient name or public key (repeatable)",
+	)
+	updateCmd.MarkFlagsMutuallyExclusive("key", "password", "recipient")
+	updateCmd.MarkFlagsMutuallyExclusive("key", "password-file", "recipient")
+
 	viper.BindPFlag("upd-file", updateCmd.Flags().Lookup("file"))
 	viper.BindPFlag("upd-filetype", updateCmd.Flags().Lookup("filetype"))
 	viper.BindPFlag("upd-expiresIn", updateCmd.Flags().Lookup("expires"))
-	viper.BindPFlag("upd-accessKey", updateCmd.Flags().Lookup("access-key"))
 	viper.BindPFlag("upd-uuid", updateCmd.Flags().Lookup("uuid"))
+	viper.BindPFlag("upd-key", updateCmd.Flags().Lookup("key"))
+	viper.BindPFlag("upd-password", updateCmd.Flags().Lookup("password"))
+	viper.BindPFlag("upd-passwordFile", updateCmd.Flags().Lookup("password-file"))
+	viper.BindPFlag("upd-identity", updateCmd.Flags().Lookup("identity"))
+	viper.BindPFlag("upd-recipient", updateCmd.Flags().Lookup("recipient"))
 	viper.SetDefault("upd-file", "")
 	viper.SetDefault("upd-filetype", "")
 	viper.SetDefault("upd-expiresIn", 0)
-	viper.SetDefault("upd-accessKey", "")
 	viper.SetDefault("upd-uuid", "")
+	viper.SetDefault("upd-key", "")
+	viper.SetDefault("upd-password", false)
+	viper.SetDefault("upd-passwordFile", "")
+	viper.SetDefault("upd-identity", "")
+	viper.SetDefault("upd-recipient", []string{})
+}
+
+// unlockForUpdate fetches the paste being updated and reads the password,
+// recipients or identities needed to encrypt its new content the same way
+func unlockForUpdate(ctx context.Context, client *api.Client, req *api.UpdateRequest) error {
+	current, err := client.GetPaste(ctx, req.UUID)
+	if err != nil {
+		return err
+	}
+	req.Current = &current
+
+	if names := viper.GetStringSlice("upd-recipient"); len(names) > 0 {
+		_, entries := loadRecipients()
+		for _, name := range names {
+			r, err := keys.Resolve(entries, name)
+			if err != nil {
+				return invalidError(err)
+			}
+			req.Recipients = append(req.Recipients, r)
+		}
+	}
+
+	passFile := viper.GetString("upd-passwordFile")
+	usePassword := viper.GetBool("upd-password") || passFile != ""
+	switch {
+	case current.IsPasswordProtected(), usePassword && !current.IsEncrypted():
+		// Only confirm a password which is new to the paste
+		req.Password, err = readPassword(passFile, !current.IsEncrypted())
+		return err
+	case usePassword:
+		return usageError(errors.New("Paste is not password protected"))
+	case current.IsEncryptedToRecipients() && req.Recipients == nil:
+		path, err := identityPath(viper.GetString("upd-identity"))
+		if err != nil {
+			return err
+		}
+		req.Identities, err = keys.LoadIdentities(path)
+		return err
+	case current.IsEncrypted() && !current.IsEncryptedToRecipients() &&
+		req.Key == nil:
+		return &codedError{errors.New(
+			"Paste is encrypted, use the paste URL or --key to update it",
+		), exitForbidden}
+	}
+	return nil
 }
@@ -0,0 +1,237 @@
+/*
+Copyright © 2022 Harry Law <hrryslw@pm.me>
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+package filetype
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+
+	"gopkg.in/yaml.v3"
+)
+
+// heuristic tells whether content is in a language by looking for lines
+// typical of it
+type heuristic struct {
+	filetype string
+	// any is matched against the whole content, one match is enough
+	any []*regexp.Regexp
+	// score patterns are each worth a point, min points are needed
+	score []*regexp.Regexp
+	min   int
+}
+
+// heuristics are tried in order, so languages with distinctive markers come
+// before those recognised by looser patterns
+var heuristics = []heuristic{
+	{
+		filetype: "php",
+		any:      []*regexp.Regexp{regexp.MustCompile(`^\s*<\?php\b`)},
+	},
+	{
+		filetype: "xml",
+		any: []*regexp.Regexp{
+			regexp.MustCompile(`^\s*<\?xml\s`),
+			regexp.MustCompile(`(?i)^\s*<!DOCTYPE\s+html`),
+			regexp.MustCompile(`(?i)^\s*<html[\s>]`),
+		},
+	},
+	{
+		filetype: "diff",
+		any: []*regexp.Regexp{
+			regexp.MustCompile(`(?m)^diff --git a/`),
+			regexp.MustCompile(`(?m)^--- \S.*\n\+\+\+ \S.*\n@@ -\d`),
+		},
+	},
+	{
+		filetype: "go",
+		score: []*regexp.Regexp{
+			regexp.MustCompile(`(?m)^package [a-z_][a-z0-9_]*\s*$`),
+			regexp.MustCompile(`(?m)^import (?:\(|"[^"]+")`),
+			regexp.MustCompile(`(?m)^func (?:\([^)]*\) )?[A-Za-z_]\w*\(`),
+			regexp.MustCompile(`:= `),
+		},
+		min: 2,
+	},
+	{
+		filetype: "rust",
+		score: []*regexp.Regexp{
+			regexp.MustCompile(`(?m)^\s*(?:pub )?fn [a-z_]\w*\(`),
+			regexp.MustCompile(`(?m)^use [a-z_]\w*::`),
+			regexp.MustCompile(`\blet mut\b`),
+			regexp.MustCompile(`(?m)^\s*impl\b`),
+		},
+		min: 2,
+	},
+	{
+		filetype: "cpp",
+		any: []*regexp.Regexp{
+			regexp.MustCompile(`(?m)^#include <(?:iostream|string|vector|map|memory)>`),
+			regexp.MustCompile(`\bstd::`),
+		},
+	},
+	{
+		filetype: "c",
+		any: []*regexp.Regexp{
+			regexp.MustCompile(`(?m)^#include [<"][\w/]+\.h[>"]`),
+		},
+	},
+	{
+		filetype: "python",
+		score: []*regexp.Regexp{
+			regexp.MustCompile(`(?m)^\s*def \w+\(.*\)(?:\s*->\s*[^:]+)?:\s*$`),
+			regexp.MustCompile(`(?m)^(?:from [\w.]+ )?import \w+`),
+			regexp.MustCompile(`(?m)^\s*class \w+(?:\(.*\))?:\s*$`),
+			regexp.MustCompile(`if __name__ == ['"]__main__['"]:`),
+			regexp.MustCompile(`(?m)^\s*(?:elif|except|finally)\b.*:\s*$`),
+			regexp.MustCompile(`\bself\.\w+`),
+		},
+		min: 2,
+	},
+	{
+		filetype: "dockerfile",
+		any: []*regexp.Regexp{
+			regexp.MustCompile(`(?m)\A(?:\s*#.*\n)*\s*FROM \S+`),
+		},
+	},
+	{
+		filetype: "javascript",
+		score: []*regexp.Regexp{
+			regexp.MustCompile(`\bconst \w+ = require\(`),
+			regexp.MustCompile(`(?m)^import .* from ['"]`),
+			regexp.MustCompile(`\bfunction\s*\w*\s*\(`),
+			regexp.MustCompile(`=>\s*\{`),
+			regexp.MustCompile(`\bconsole\.log\(`),
+			regexp.MustCompile(`(?m)^(?:export )?(?:const|let|var) \w+ = `),
+		},
+		min: 2,
+	},
+	{
+		filetype: "sql",
+		any: []*regexp.Regexp{
+			regexp.MustCompile(`(?im)^\s*(?:SELECT .+ FROM|INSERT INTO|CREATE (?:TABLE|INDEX|VIEW)|ALTER TABLE|DROP TABLE|UPDATE \w+ SET|DELETE FROM)\b`),
+		},
+	},
+	{
+		filetype: "bash",
+		score: []*regexp.Regexp{
+			regexp.MustCompile(`(?m)^\s*(?:export|local|readonly) \w+=`),
+			regexp.MustCompile(`(?m)^\s*(?:fi|done|esac)\s*$`),
+			regexp.MustCompile(`(?m)^\s*(?:if|while) \[\[? .*\]\]?; ?(?:then|do)\s*$`),
+			regexp.MustCompile(`\$\{?\w+\}?`),
+			regexp.MustCompile(`(?m)^\s*\w+\(\)\s*\{\s*$`),
+			regexp.MustCompile(`(?m)^\s*(?:echo|cd|set -\w+) `),
+		},
+		min: 3,
+	},
+	{
+		filetype: "markdown",
+		score: []*regexp.Regexp{
+			regexp.MustCompile(`(?m)^#{1,6} \S`),
+			regexp.MustCompile("(?m)^```"),
+			regexp.MustCompile(`(?m)^\s*[-*] \S`),
+			regexp.MustCompile(`\[[^\]]+\]\([^)]+\)`),
+			regexp.MustCompile(`\*\*[^*]+\*\*`),
+		},
+		min: 2,
+	},
+	{
+		filetype: "ini",
+		score: []*regexp.Regexp{
+			regexp.MustCompile(`(?m)^\[[\w. "-]+\]\s*$`),
+			regexp.MustCompile(`(?m)^[\w.-]+\s*=\s*\S`),
+		},
+		min: 2,
+	},
+}
+
+// FromContent guesses the filetype of content from what it looks like,
+// returning an empty string if it can't be told
+func FromContent(content []byte) string {
+	trimmed := bytes.TrimSpace(content)
+	if len(trimmed) == 0 {
+		return ""
+	}
+	if (trimmed[0] == '{' || trimmed[0] == '[') && json.Valid(trimmed) {
+		return "json"
+	}
+
+	for _, h := range heuristics {
+		if h.matches(content) {
+			return h.filetype
+		}
+	}
+	if looksLikeYaml(content) {
+		return "yaml"
+	}
+	return ""
+}
+
+// matches reports whether content looks like the language of the heuristic
+func (h heuristic) matches(content []byte) bool {
+	for _, re := range h.any {
+		if re.Match(content) {
+			return true
+		}
+	}
+	if len(h.score) == 0 {
+		return false
+	}
+	points := 0
+	for _, re := range h.score {
+		if re.Match(content) {
+			points++
+		}
+	}
+	return points >= h.min
+}
+
+var (
+	yamlDocStart = regexp.MustCompile(`\A(?:\s*#.*\n)*---\s*\n`)
+	yamlKey      = regexp.MustCompile(`(?m)^\s*(?:- )?[\w.-]+:(?:\s|$)`)
+)
+
+// looksLikeYaml reports whether content is a YAML document of mappings or
+// lists rather than a lone scalar, which any text would parse as
+func looksLikeYaml(content []byte) bool {
+	if !yamlDocStart.Match(content) && len(yamlKey.FindAll(content, 3)) < 2 {
+		return false
+	}
+	var v interface{}
+	if err := yaml.Unmarshal(content, &v); err != nil {
+		return false
+	}
+	switch v.(type) {
+	case map[string]interface{}, []interface{}:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,298 @@
+/*
+Copyright © 2022 Harry Law <hrryslw@pm.me>
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+package filetype
+
+import (
+	"bytes"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Plaintext is the filetype of content in no particular language
+const Plaintext = "plaintext"
+
+// aliases maps the names languages go by in modelines, shebangs and elsewhere
+// to the filetype names the paste-server accepts
+var aliases = map[string]string{
+	"golang":      "go",
+	"py":          "python",
+	"python2":     "python",
+	"python3":     "python",
+	"sh":          "bash",
+	"shell":       "bash",
+	"zsh":         "bash",
+	"ksh":         "bash",
+	"dash":        "bash",
+	"fish":        "bash",
+	"js":          "javascript",
+	"node":        "javascript",
+	"nodejs":      "javascript",
+	"ts":          "typescript",
+	"ts-node":     "typescript",
+	"deno":        "typescript",
+	"yml":         "yaml",
+	"patch":       "diff",
+	"html":        "xml",
+	"xhtml":       "xml",
+	"svg":         "xml",
+	"c++":         "cpp",
+	"cc":          "cpp",
+	"cxx":         "cpp",
+	"rb":          "ruby",
+	"rs":          "rust",
+	"kt":          "kotlin",
+	"cs":          "csharp",
+	"c#":          "csharp",
+	"pl":          "perl",
+	"hs":          "haskell",
+	"md":          "markdown",
+	"mkd":         "markdown",
+	"rscript":     "r",
+	"toml":        "ini",
+	"dosini":      "ini",
+	"conf":        "ini",
+	"make":        "makefile",
+	"docker":      "dockerfile",
+	"ps1":         "powershell",
+	"pwsh":        "powershell",
+	"text":        Plaintext,
+	"txt":         Plaintext,
+	"fundamental": Plaintext,
+}
+
+// extensions maps file extensions to filetypes
+var extensions = map[string]string{
+	".go":         "go",
+	".py":         "python",
+	".pyw":        "python",
+	".sh":         "bash",
+	".bash":       "bash",
+	".zsh":        "bash",
+	".js":         "javascript",
+	".mjs":        "javascript",
+	".cjs":        "javascript",
+	".jsx":        "javascript",
+	".ts":         "typescript",
+	".tsx":        "typescript",
+	".json":       "json",
+	".yaml":       "yaml",
+	".yml":        "yaml",
+	".xml":        "xml",
+	".html":       "xml",
+	".htm":        "xml",
+	".svg":        "xml",
+	".diff":       "diff",
+	".patch":      "diff",
+	".md":         "markdown",
+	".markdown":   "markdown",
+	".c":          "c",
+	".h":          "c",
+	".cpp":        "cpp",
+	".cc":         "cpp",
+	".cxx":        "cpp",
+	".hpp":        "cpp",
+	".rs":         "rust",
+	".java":       "java",
+	".kt":         "kotlin",
+	".swift":      "swift",
+	".cs":         "csharp",
+	".rb":         "ruby",
+	".php":        "php",
+	".pl":         "perl",
+	".lua":        "lua",
+	".r":          "r",
+	".hs":         "haskell",
+	".scala":      "scala",
+	".sql":        "sql",
+	".css":        "css",
+	".scss":       "scss",
+	".ini":        "ini",
+	".cfg":        "ini",
+	".toml":       "ini",
+	".ps1":        "powershell",
+	".dockerfile": "dockerfile",
+	".mk":         "makefile",
+	".txt":        Plaintext,
+	".log":        Plaintext,
+}
+
+// fileNames maps file names without a telling extension to filetypes
+var fileNames = map[string]string{
+	"dockerfile":    "dockerfile",
+	"containerfile": "dockerfile",
+	"makefile":      "makefile",
+	"gnumakefile":   "makefile",
+	".bashrc":       "bash",
+	".bash_profile": "bash",
+	".profile":      "bash",
+	".zshrc":        "bash",
+	".gitconfig":    "ini",
+	".editorconfig": "ini",
+}
+
+// Name maps a language name as used in modelines or by other tools to the
+// filetype name the paste-server accepts, names it doesn't know are returned
+// in lower case
+func Name(lang string) string {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if name, ok := aliases[lang]; ok {
+		return name
+	}
+	return lang
+}
+
+// Detect returns the filetype of content read from the file at path, path is
+// empty for content read from stdin. Modelines are trusted most, then the
+// file name, the shebang line and finally the content itself. An empty
+// string is returned if the filetype can't be told.
+func Detect(path string, content []byte) string {
+	if !utf8.Valid(content) || bytes.IndexByte(content, 0) >= 0 {
+		return ""
+	}
+	if ft := FromModeline(content); ft != "" {
+		return ft
+	}
+	if ft := FromPath(path); ft != "" {
+		return ft
+	}
+	if ft := FromShebang(content); ft != "" {
+		return ft
+	}
+	return FromContent(content)
+}
+
+// FromPath returns the filetype of a file from its name or extension
+func FromPath(path string) string {
+	if path == "" {
+		return ""
+	}
+	base := strings.ToLower(filepath.Base(path))
+	if ft, ok := fileNames[base]; ok {
+		return ft
+	}
+	return extensions[filepath.Ext(base)]
+}
+
+// FromShebang returns the filetype of a script from the interpreter on its
+// shebang line
+func FromShebang(content []byte) string {
+	if !bytes.HasPrefix(content, []byte("#!")) {
+		return ""
+	}
+	fields := strings.Fields(string(firstLine(content)[2:]))
+	if len(fields) == 0 {
+		return ""
+	}
+	interpreter := filepath.Base(fields[0])
+	if interpreter == "env" {
+		// Skip options such as -S given to env
+		for _, f := range fields[1:] {
+			if !strings.HasPrefix(f, "-") {
+				interpreter = f
+				break
+			}
+		}
+	}
+	// Drop versions such as python3.11
+	interpreter = strings.TrimRight(interpreter, "0123456789.")
+	switch ft := Name(interpreter); ft {
+	case "env", "":
+		return ""
+	default:
+		return ft
+	}
+}
+
+var (
+	vimModeline = regexp.MustCompile(
+		`(?:^|\s)(?:vi|vim|ex):.*?\b(?:ft|filetype|syntax|syn)=([A-Za-z0-9_+#-]+)`,
+	)
+	emacsModeline = regexp.MustCompile(`-\*-\s*(.*?)\s*-\*-`)
+	emacsMode     = regexp.MustCompile(`(?i)(?:^|;)\s*mode:\s*([A-Za-z0-9_+#-]+)`)
+)
+
+// modelineLines is how many lines at the start and end of content are
+// searched for vim modelines
+const modelineLines = 5
+
+// FromModeline returns the filetype set in a vim modeline in the first or
+// last lines or an emacs modeline in the first two lines
+func FromModeline(content []byte) string {
+	lines := bytes.Split(content, []byte("\n"))
+
+	// Emacs allows the mode line after a shebang
+	for i := 0; i < len(lines) && i < 2; i++ {
+		m := emacsModeline.FindSubmatch(lines[i])
+		if m == nil {
+			continue
+		}
+		vars := string(m[1])
+		if !strings.Contains(vars, ":") {
+			return emacsName(vars)
+		}
+		if mode := emacsMode.FindStringSubmatch(vars); mode != nil {
+			return emacsName(mode[1])
+		}
+	}
+
+	check := func(line []byte) string {
+		if m := vimModeline.FindSubmatch(line); m != nil {
+			return Name(string(m[1]))
+		}
+		return ""
+	}
+	for i := 0; i < len(lines) && i < modelineLines; i++ {
+		if ft := check(lines[i]); ft != "" {
+			return ft
+		}
+	}
+	for i := len(lines) - 1; i >= modelineLines && i >= len(lines)-modelineLines; i-- {
+		if ft := check(lines[i]); ft != "" {
+			return ft
+		}
+	}
+	return ""
+}
+
+// emacsName maps an emacs major mode to a filetype, e.g. python-mode
+func emacsName(mode string) string {
+	return Name(strings.TrimSuffix(strings.TrimSuffix(mode, "-ts"), "-mode"))
+}
+
+// firstLine returns the first line of content without its line ending
+func firstLine(content []byte) []byte {
+	if i := bytes.IndexByte(content, '\n'); i >= 0 {
+		content = content[:i]
+	}
+	return bytes.TrimSuffix(content, []byte("\r"))
+}
@@ -0,0 +1,211 @@
+/*
+Copyright © 2022 Harry Law <hrryslw@pm.me>
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+package highlight
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/h5law/paste-cli/filetype"
+)
+
+// Kind is the kind of a token in highlighted content
+type Kind int
+
+const (
+	Plain Kind = iota
+	Comment
+	Keyword
+	Type
+	String
+	Number
+	Constant
+	Function
+	Tag
+	Attr
+	Key
+	Variable
+	Meta
+	Heading
+	Inserted
+	Deleted
+)
+
+// ColorMode is the set of colors the terminal supports
+type ColorMode int
+
+const (
+	// Color256 uses the xterm 256 color palette
+	Color256 ColorMode = iota
+	// TrueColor uses 24-bit colors
+	TrueColor
+)
+
+// MaxSize is the largest content highlighted, larger content is left as is
+// as the lexers get slow
+const MaxSize = 1 << 20
+
+// Lexer splits content in a language into tokens
+type Lexer struct {
+	// re matches every token, each alternative but the last is a rule whose
+	// kind is at the same index in kinds and the last matches identifiers
+	re    *regexp.Regexp
+	kinds []Kind
+	// words maps identifiers to their kind, others are Plain or Function
+	// when followed by a parenthesis
+	words map[string]Kind
+	// fold matches words case insensitively
+	fold bool
+	// calls marks identifiers followed by a parenthesis as functions
+	calls bool
+}
+
+// rule is a pattern matching tokens of a kind
+type rule struct {
+	pattern string
+	kind    Kind
+}
+
+// identPattern matches identifiers in most languages
+const identPattern = `[A-Za-z_][A-Za-z0-9_]*`
+
+// newLexer compiles the rules into a lexer, rules are tried in order
+func newLexer(rules []rule, words map[string]Kind, fold, calls bool) *Lexer {
+	parts := make([]string, 0, len(rules)+1)
+	kinds := make([]Kind, 0, len(rules))
+	for _, r := range rules {
+		parts = append(parts, "("+r.pattern+")")
+		kinds = append(kinds, r.kind)
+	}
+	parts = append(parts, "("+identPattern+")")
+	return &Lexer{
+		re:    regexp.MustCompile(strings.Join(parts, "|")),
+		kinds: kinds,
+		words: words,
+		fold:  fold,
+		calls: calls,
+	}
+}
+
+// LexerFor returns the lexer for a filetype, or nil if there isn't one
+func LexerFor(fileType string) *Lexer {
+	return lexers[filetype.Name(fileType)]
+}
+
+// Highlight colors content in the language of the filetype given for a
+// terminal, content is returned unchanged if there is no lexer for the
+// filetype or it is too large
+func Highlight(content []byte, fileType string, theme Theme, mode ColorMode) []byte {
+	l := LexerFor(fileType)
+	if l == nil || len(content) > MaxSize {
+		return content
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(len(content) * 2)
+	last := 0
+	for _, m := range l.re.FindAllSubmatchIndex(content, -1) {
+		buf.Write(content[last:m[0]])
+		last = m[1]
+		writeToken(&buf, content[m[0]:m[1]], theme[l.kind(content, m)], mode)
+	}
+	buf.Write(content[last:])
+	return buf.Bytes()
+}
+
+// kind returns the kind of the token matched by the submatch indexes m
+func (l *Lexer) kind(content []byte, m []int) Kind {
+	for i, k := range l.kinds {
+		if m[2*(i+1)] >= 0 {
+			return k
+		}
+	}
+
+	word := string(content[m[0]:m[1]])
+	if l.fold {
+		word = strings.ToLower(word)
+	}
+	if k, ok := l.words[word]; ok {
+		return k
+	}
+	if l.calls {
+		rest := bytes.TrimLeft(content[m[1]:], " \t")
+		if len(rest) > 0 && rest[0] == '(' {
+			return Function
+		}
+	}
+	return Plain
+}
+
+// writeToken writes text in the style given, styles are reset before each
+// newline so colors don't bleed into the next line in pagers
+func writeToken(buf *bytes.Buffer, text []byte, style Style, mode ColorMode) {
+	if style == (Style{}) {
+		buf.Write(text)
+		return
+	}
+	seq := style.sequence(mode)
+	for i, line := range bytes.Split(text, []byte("\n")) {
+		if i > 0 {
+			buf.WriteByte('\n')
+		}
+		if len(line) == 0 {
+			continue
+		}
+		buf.WriteString(seq)
+		buf.Write(line)
+		buf.WriteString(reset)
+	}
+}
+
+// reset clears all styles
+const reset = "\x1b[0m"
+
+// sequence returns the escape sequence setting the style
+func (s Style) sequence(mode ColorMode) string {
+	var codes []string
+	if s.Bold {
+		codes = append(codes, "1")
+	}
+	if s.Italic {
+		codes = append(codes, "3")
+	}
+	if s.Color != (RGB{}) {
+		if mode == TrueColor {
+			codes = append(codes, fmt.Sprintf("38;2;%d;%d;%d", s.Color.R, s.Color.G, s.Color.B))
+		} else {
+			codes = append(codes, fmt.Sprintf("38;5;%d", s.Color.xterm256()))
+		}
+	}
+	return "\x1b[" + strings.Join(codes, ";") + "m"
+}
@@ -0,0 +1,349 @@
+/*
+Copyright © 2022 Harry Law <hrryslw@pm.me>
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+package highlight
+
+import "strings"
+
+// Patterns shared by the lexers
+const (
+	slashComment = `//[^\n]*`
+	blockComment = `/\*(?s:.*?)(?:\*/|\z)`
+	hashComment  = `(?m:(?:^|[ \t]+)#[^\n]*)`
+	dqString     = `"(?:[^"\\\n]|\\.)*"`
+	sqString     = `'(?:[^'\\\n]|\\.)*'`
+	btString     = "`(?:[^`\\\\]|\\\\.)*`"
+	number       = `\b(?:0[xX][0-9a-fA-F_]+|0[bB][01_]+|[0-9][0-9_]*(?:\.[0-9_]+)?(?:[eE][+-]?[0-9]+)?)\b`
+	shellVar     = `\$(?:\{[^}\n]*\}|[A-Za-z_][A-Za-z0-9_]*|[0-9@#?$!*-])`
+)
+
+// words maps the space separated words in each list to the kind given
+func words(lists map[Kind]string) map[string]Kind {
+	m := make(map[string]Kind)
+	for kind, list := range lists {
+		for _, w := range strings.Fields(list) {
+			m[w] = kind
+		}
+	}
+	return m
+}
+
+// clike returns a lexer for languages with C style comments, strings and
+// numbers, extra rules are tried before the strings
+func clike(keywords, types, constants string, extra ...rule) *Lexer {
+	rules := []rule{
+		{slashComment, Comment},
+		{blockComment, Comment},
+	}
+	rules = append(rules, extra...)
+	rules = append(rules,
+		rule{dqString, String},
+		rule{sqString, String},
+		rule{number, Number},
+	)
+	return newLexer(rules, words(map[Kind]string{
+		Keyword:  keywords,
+		Type:     types,
+		Constant: constants,
+	}), false, true)
+}
+
+// cPreprocessor matches C preprocessor directives and included headers
+const cPreprocessor = `(?m:^[ \t]*#[ \t]*[a-z]+(?:[ \t]*<[^>\n]*>)?)`
+
+const (
+	cKeywords = `auto break case const continue default do else enum extern
+		for goto if inline register restrict return sizeof static struct switch
+		typedef union volatile while`
+	cTypes = `char double float int long short signed unsigned void bool
+		size_t ssize_t int8_t int16_t int32_t int64_t uint8_t uint16_t
+		uint32_t uint64_t FILE`
+	jsKeywords = `async await break case catch class const continue debugger
+		default delete do else export extends finally for from function if
+		import in instanceof let new of return static super switch this throw
+		try typeof var void while with yield`
+)
+
+// lexers are the lexers built in by filetype
+var lexers = map[string]*Lexer{
+	"go": clike(
+		`break case chan const continue default defer else fallthrough for
+		func go goto if import interface map package range return select
+		struct switch type var`,
+		`bool byte complex64 complex128 error float32 float64 int int8 int16
+		int32 int64 rune string uint uint8 uint16 uint32 uint64 uintptr any
+		comparable`,
+		`true false nil iota`,
+		rule{btString, String},
+	),
+	"c": clike(cKeywords, cTypes, `NULL true false`,
+		rule{cPreprocessor, Meta},
+	),
+	"cpp": clike(
+		cKeywords+` alignas alignof catch class constexpr consteval decltype
+		delete explicit friend mutable namespace new noexcept operator override
+		private protected public static_assert template this throw try
+		typename using virtual final`,
+		cTypes+` wchar_t char8_t char16_t char32_t auto string vector map`,
+		`NULL nullptr true false`,
+		rule{cPreprocessor, Meta},
+	),
+	"java": clike(
+		`abstract assert break case catch class const continue default do
+		else enum extends final finally for goto if implements import
+		instanceof interface native new package private protected public
+		return static strictfp super switch synchronized this throw throws
+		transient try volatile while var record`,
+		`boolean byte char double float int long short void String Object
+		Integer Long List Map`,
+		`true false null`,
+		rule{`@[A-Za-z_][\w.]*`, Meta},
+	),
+	"kotlin": clike(
+		`as break class continue do else for fun if in interface is object
+		package return super this throw try typealias val var when while
+		import private protected public internal override open abstract data
+		sealed companion suspend`,
+		`Any Boolean Byte Char Double Float Int Long Short String Unit List Map`,
+		`true false null`,
+		rule{`@[A-Za-z_][\w.]*`, Meta},
+	),
+	"swift": clike(
+		`associatedtype class deinit enum extension fileprivate func import
+		init inout internal let open operator private protocol public static
+		struct subscript typealias var break case continue default defer do
+		else fallthrough for guard if in repeat return switch where while as
+		catch is rethrows throw throws try async await`,
+		`Int Double Float String Bool Character Array Dictionary Set Any Void`,
+		`true false nil self Self`,
+		rule{`@[A-Za-z_]\w*`, Meta},
+	),
+	"csharp": clike(
+		`abstract as base break case catch checked class const continue
+		default delegate do else enum event explicit extern finally fixed for
+		foreach goto if implicit in interface internal is lock namespace new
+		operator out override params private protected public readonly ref
+		return sealed sizeof stackalloc static struct switch this throw try
+		typeof unchecked unsafe using virtual volatile while var async await`,
+		`bool byte char decimal double float int long object sbyte short
+		string uint ulong ushort void`,
+		`true false null`,
+		rule{`(?m:^[ \t]*#[ \t]*[a-z]+)`, Meta},
+	),
+	"scala": clike(
+		`abstract case catch class def do else extends final finally for
+		forSome if implicit import lazy match new object override package
+		private protected return sealed super this throw trait try type val
+		var while with yield`,
+		`Int Long Double Float Boolean Char String Unit Any Option List Map`,
+		`true false null None Nil`,
+	),
+	"rust": clike(
+		`as async await break const continue crate dyn else enum extern fn
+		for if impl in let loop match mod move mut pub ref return self Self
+		static struct super trait type unsafe use where while`,
+		`bool char f32 f64 i8 i16 i32 i64 i128 isize str u8 u16 u32 u64 u128
+		usize String Vec Option Result Box`,
+		`true false None Some Ok Err`,
+		rule{`#!?\[[^\]\n]*\]`, Meta},
+		rule{`'(?:[^'\\\n]|\\.)'`, String},
+		rule{`'[A-Za-z_]\w*`, Meta},
+		rule{`[A-Za-z_]\w*!`, Function},
+	),
+	"javascript": clike(jsKeywords, ``, `true false null undefined NaN Infinity`,
+		rule{btString, String},
+	),
+	"typescript": clike(
+		jsKeywords+` abstract as declare enum implements interface keyof
+		namespace private protected public readonly type`,
+		`any boolean never number object string symbol unknown void bigint`,
+		`true false null undefined NaN Infinity`,
+		rule{btString, String},
+		rule{`@[A-Za-z_]\w*`, Meta},
+	),
+	"php": clike(
+		`abstract and as break case catch class clone const continue declare
+		default do echo else elseif empty enddeclare endfor endforeach endif
+		endswitch endwhile extends final finally fn for foreach function
+		global if implements include include_once instanceof insteadof
+		interface isset list match namespace new or print private protected
+		public require require_once return static switch throw trait try
+		unset use var while xor yield`,
+		`array bool callable float int iterable mixed object string void`,
+		`true false null TRUE FALSE NULL`,
+		rule{`<\?php|\?>`, Meta},
+		rule{`(?m:(?:^|[ \t]+)#[^\n]*)`, Comment},
+		rule{`\$[A-Za-z_]\w*`, Variable},
+	),
+	"python": newLexer([]rule{
+		{`#[^\n]*`, Comment},
+		{`(?i:[rbuf]{0,2})"""(?s:.*?)(?:"""|\z)`, String},
+		{`(?i:[rbuf]{0,2})'''(?s:.*?)(?:'''|\z)`, String},
+		{`(?i:[rbuf]{0,2})` + dqString, String},
+		{`(?i:[rbuf]{0,2})` + sqString, String},
+		{`(?m:^[ \t]*@[A-Za-z_][\w.]*)`, Meta},
+		{number, Number},
+	}, words(map[Kind]string{
+		Keyword: `and as assert async await break class continue def del elif
+			else except finally for from global if import in is lambda
+			nonlocal not or pass raise return try while with yield match case`,
+		Type:     `int str float complex list dict set frozenset tuple bool bytes bytearray object type`,
+		Constant: `True False None self cls`,
+	}), false, true),
+	"ruby": newLexer([]rule{
+		{`#[^\n]*`, Comment},
+		{`(?s)"(?:[^"\\]|\\.)*"`, String},
+		{sqString, String},
+		{`:[A-Za-z_]\w*[?!]?`, Constant},
+		{`@{1,2}[A-Za-z_]\w*`, Variable},
+		{number, Number},
+	}, words(map[Kind]string{
+		Keyword: `alias and begin break case class def defined? do else elsif
+			end ensure for if in module next not or redo rescue retry return
+			super then undef unless until when while yield require
+			attr_accessor attr_reader attr_writer private protected public`,
+		Constant: `true false nil self`,
+	}), false, true),
+	"lua": newLexer([]rule{
+		{`--\[\[(?s:.*?)(?:\]\]|\z)`, Comment},
+		{`--[^\n]*`, Comment},
+		{`\[\[(?s:.*?)(?:\]\]|\z)`, String},
+		{dqString, String},
+		{sqString, String},
+		{number, Number},
+	}, words(map[Kind]string{
+		Keyword: `and break do else elseif end for function goto if in local
+			not or repeat return then until while`,
+		Constant: `true false nil`,
+	}), false, true),
+	"bash": newLexer([]rule{
+		{shellVar, Variable},
+		{hashComment, Comment},
+		{`"(?:[^"\\]|\\.)*"`, String},
+		{`'[^']*'`, String},
+		{`\b[0-9]+\b`, Number},
+	}, words(map[Kind]string{
+		Keyword: `if then else elif fi for while until do done case esac in
+			function return local export readonly declare select time`,
+		Function: `echo printf read cd pwd set unset shift exit source eval
+			exec trap test alias unalias type command builtin`,
+		Constant: `true false`,
+	}), false, false),
+	"json": newLexer([]rule{
+		{dqString + `[ \t]*:`, Key},
+		{dqString, String},
+		{`-?\b[0-9]+(?:\.[0-9]+)?(?:[eE][+-]?[0-9]+)?\b`, Number},
+	}, words(map[Kind]string{
+		Constant: `true false null`,
+	}), false, false),
+	"yaml": newLexer([]rule{
+		{hashComment, Comment},
+		{`(?m:^(?:---|\.\.\.)[ \t]*$)`, Meta},
+		{`(?m:^[ \t]*(?:-[ \t]+)*[^\s#'"\-][^:\n#]*:(?:[ \t]|$))`, Key},
+		{`(?m:^[ \t]*-(?:[ \t]|$))`, Keyword},
+		{`[&*][A-Za-z0-9_-]+`, Meta},
+		{`!!?[A-Za-z]+`, Meta},
+		{dqString, String},
+		{`'(?:[^']|'')*'`, String},
+		{`[-+]?\b[0-9]+(?:\.[0-9]+)?\b`, Number},
+	}, words(map[Kind]string{
+		Constant: `true false null yes no on off`,
+	}), true, false),
+	"xml": newLexer([]rule{
+		{`<!--(?s:.*?)(?:-->|\z)`, Comment},
+		{`<!\[CDATA\[(?s:.*?)(?:\]\]>|\z)`, String},
+		{`<[?!][^>]*>`, Meta},
+		{`</?[A-Za-z_][\w:.-]*`, Tag},
+		{`/?>`, Tag},
+		{`[A-Za-z_][\w:.-]*=`, Attr},
+		{`"[^"]*"`, String},
+		{`'[^']*'`, String},
+		{`&#?[A-Za-z0-9]+;`, Constant},
+	}, nil, false, false),
+	"diff": newLexer([]rule{
+		{`(?m:^(?:diff|index|new file|deleted file|similarity|rename|\+\+\+|---)[^\n]*)`, Heading},
+		{`(?m:^@@[^\n]*)`, Meta},
+		{`(?m:^\+[^\n]*)`, Inserted},
+		{`(?m:^-[^\n]*)`, Deleted},
+	}, nil, false, false),
+	"markdown": newLexer([]rule{
+		{"(?ms:^```.*?(?:^```[ \\t]*$|\\z))", String},
+		{`(?m:^#{1,6}[ \t][^\n]*)`, Heading},
+		{`(?m:^>[^\n]*)`, Comment},
+		{`(?m:^[ \t]*(?:[-*+]|[0-9]+\.)[ \t])`, Keyword},
+		{"`[^`\\n]+`", String},
+		{`\*\*[^*\n]+\*\*|__[^_\n]+__`, Keyword},
+		{`!?\[[^\]\n]*\]\([^)\n]*\)`, Attr},
+	}, nil, false, false),
+	"ini": newLexer([]rule{
+		{`(?m:^[ \t]*[;#][^\n]*)`, Comment},
+		{`(?m:^[ \t]*\[[^\]\n]*\][ \t]*$)`, Heading},
+		{`(?m:^[ \t]*[A-Za-z0-9_.\-" ]+?[ \t]*=)`, Key},
+		{dqString, String},
+		{sqString, String},
+		{number, Number},
+	}, words(map[Kind]string{
+		Constant: `true false yes no on off`,
+	}), true, false),
+	"dockerfile": newLexer([]rule{
+		{`(?m:^[ \t]*#[^\n]*)`, Comment},
+		{`(?im:^[ \t]*(?:FROM|RUN|CMD|LABEL|EXPOSE|ENV|ADD|COPY|ENTRYPOINT|VOLUME|USER|WORKDIR|ARG|ONBUILD|STOPSIGNAL|HEALTHCHECK|SHELL|MAINTAINER)\b)`, Keyword},
+		{shellVar, Variable},
+		{dqString, String},
+		{sqString, String},
+	}, words(map[Kind]string{
+		Keyword: `as`,
+	}), true, false),
+	"makefile": newLexer([]rule{
+		{`#[^\n]*`, Comment},
+		{`\$(?:\([^)\n]*\)|\{[^}\n]*\}|.)`, Variable},
+		{`(?m:^[^\s:#=][^:#=\n]*::?)`, Function},
+	}, words(map[Kind]string{
+		Keyword: `ifeq ifneq ifdef ifndef else endif include define endef
+			export unexport override`,
+	}), false, false),
+	"sql": newLexer([]rule{
+		{`--[^\n]*`, Comment},
+		{blockComment, Comment},
+		{`'(?:[^']|'')*'`, String},
+		{number, Number},
+	}, words(map[Kind]string{
+		Keyword: `select from where and or not insert into values update set
+			delete create table index view drop alter add column primary key
+			foreign references join inner left right outer full on as group
+			by order having limit offset distinct union all exists in is
+			like between case when then else end begin commit rollback
+			transaction default unique constraint if returning with asc desc`,
+		Type: `int integer bigint smallint serial bigserial decimal numeric
+			real float double precision varchar char text boolean bool date
+			time timestamp timestamptz interval uuid json jsonb blob`,
+		Constant: `null true false`,
+	}), true, true),
+}
@@ -0,0 +1,196 @@
+/*
+Copyright © 2022 Harry Law <hrryslw@pm.me>
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+package highlight
+
+import (
+	"fmt"
+	"sort"
+)
+
+// RGB is a 24-bit color
+type RGB struct {
+	R, G, B uint8
+}
+
+// Style is how tokens of a kind are shown
+type Style struct {
+	Color  RGB
+	Bold   bool
+	Italic bool
+}
+
+// Theme maps the kinds of tokens to their style, kinds missing are plain
+type Theme map[Kind]Style
+
+// DefaultTheme is the name of the theme used unless another is set
+const DefaultTheme = "monokai"
+
+// hex returns the color given as 0xRRGGBB
+func hex(c uint32) RGB {
+	return RGB{uint8(c >> 16), uint8(c >> 8), uint8(c)}
+}
+
+// themes are the themes built in by name
+var themes = map[string]Theme{
+	"monokai": {
+		Comment:  {Color: hex(0x75715e), Italic: true},
+		Keyword:  {Color: hex(0xf92672)},
+		Type:     {Color: hex(0x66d9ef), Italic: true},
+		String:   {Color: hex(0xe6db74)},
+		Number:   {Color: hex(0xae81ff)},
+		Constant: {Color: hex(0xae81ff)},
+		Function: {Color: hex(0xa6e22e)},
+		Tag:      {Color: hex(0xf92672)},
+		Attr:     {Color: hex(0xa6e22e)},
+		Key:      {Color: hex(0x66d9ef)},
+		Variable: {Color: hex(0xfd971f)},
+		Meta:     {Color: hex(0xfd971f)},
+		Heading:  {Color: hex(0xf8f8f2), Bold: true},
+		Inserted: {Color: hex(0xa6e22e)},
+		Deleted:  {Color: hex(0xf92672)},
+	},
+	"solarized-dark": {
+		Comment:  {Color: hex(0x586e75), Italic: true},
+		Keyword:  {Color: hex(0x859900)},
+		Type:     {Color: hex(0xb58900)},
+		String:   {Color: hex(0x2aa198)},
+		Number:   {Color: hex(0xd33682)},
+		Constant: {Color: hex(0xcb4b16)},
+		Function: {Color: hex(0x268bd2)},
+		Tag:      {Color: hex(0x268bd2)},
+		Attr:     {Color: hex(0x93a1a1)},
+		Key:      {Color: hex(0x268bd2)},
+		Variable: {Color: hex(0xb58900)},
+		Meta:     {Color: hex(0x6c71c4)},
+		Heading:  {Color: hex(0xcb4b16), Bold: true},
+		Inserted: {Color: hex(0x859900)},
+		Deleted:  {Color: hex(0xdc322f)},
+	},
+	"nord": {
+		Comment:  {Color: hex(0x616e88), Italic: true},
+		Keyword:  {Color: hex(0x81a1c1)},
+		Type:     {Color: hex(0x8fbcbb)},
+		String:   {Color: hex(0xa3be8c)},
+		Number:   {Color: hex(0xb48ead)},
+		Constant: {Color: hex(0x81a1c1)},
+		Function: {Color: hex(0x88c0d0)},
+		Tag:      {Color: hex(0x81a1c1)},
+		Attr:     {Color: hex(0x8fbcbb)},
+		Key:      {Color: hex(0x88c0d0)},
+		Variable: {Color: hex(0xd8dee9)},
+		Meta:     {Color: hex(0x5e81ac)},
+		Heading:  {Color: hex(0x88c0d0), Bold: true},
+		Inserted: {Color: hex(0xa3be8c)},
+		Deleted:  {Color: hex(0xbf616a)},
+	},
+	"github": {
+		Comment:  {Color: hex(0x6a737d), Italic: true},
+		Keyword:  {Color: hex(0xd73a49)},
+		Type:     {Color: hex(0x6f42c1)},
+		String:   {Color: hex(0x032f62)},
+		Number:   {Color: hex(0x005cc5)},
+		Constant: {Color: hex(0x005cc5)},
+		Function: {Color: hex(0x6f42c1)},
+		Tag:      {Color: hex(0x22863a)},
+		Attr:     {Color: hex(0x6f42c1)},
+		Key:      {Color: hex(0x005cc5)},
+		Variable: {Color: hex(0xe36209)},
+		Meta:     {Color: hex(0xe36209)},
+		Heading:  {Color: hex(0x005cc5), Bold: true},
+		Inserted: {Color: hex(0x22863a)},
+		Deleted:  {Color: hex(0xb31d28)},
+	},
+}
+
+// ThemeNames returns the names of the themes built in
+func ThemeNames() []string {
+	names := make([]string, 0, len(themes))
+	for name := range themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LookupTheme returns the theme built in with the name given
+func LookupTheme(name string) (Theme, error) {
+	if t, ok := themes[name]; ok {
+		return t, nil
+	}
+	return nil, fmt.Errorf("Unknown theme %q (one of %v)", name, ThemeNames())
+}
+
+// cubeLevels are the levels of each channel in the xterm 6x6x6 color cube
+var cubeLevels = [6]int{0, 95, 135, 175, 215, 255}
+
+// xterm256 returns the closest color in the xterm 256 color palette, either
+// from the color cube or the grayscale ramp
+func (c RGB) xterm256() int {
+	nearest := func(v uint8) int {
+		best := 0
+		for i, l := range cubeLevels {
+			if abs(int(v)-l) < abs(int(v)-cubeLevels[best]) {
+				best = i
+			}
+		}
+		return best
+	}
+	r, g, b := nearest(c.R), nearest(c.G), nearest(c.B)
+	cube := 16 + 36*r + 6*g + b
+	cubeDist := dist(c, cubeLevels[r], cubeLevels[g], cubeLevels[b])
+
+	// Grayscale ramp from 8 to 238 in steps of 10
+	avg := (int(c.R) + int(c.G) + int(c.B)) / 3
+	step := (avg - 8 + 5) / 10
+	if step < 0 {
+		step = 0
+	} else if step > 23 {
+		step = 23
+	}
+	gray := 8 + 10*step
+	if dist(c, gray, gray, gray) < cubeDist {
+		return 232 + step
+	}
+	return cube
+}
+
+// dist returns the squared distance between two colors
+func dist(c RGB, r, g, b int) int {
+	dr, dg, db := int(c.R)-r, int(c.G)-g, int(c.B)-b
+	return dr*dr + dg*dg + db*db
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,213 @@
+/*
+Copyright © 2022 Harry Law <hrryslw@pm.me>
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+package keys
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/h5law/paste-cli/api"
+	"github.com/h5law/paste-cli/utils"
+)
+
+// Entry is a named recipient in the recipients file
+type Entry struct {
+	Name      string
+	Recipient *api.Recipient
+}
+
+// DefaultIdentityPath returns the path of the identity file used by default
+func DefaultIdentityPath() (string, error) {
+	dir, err := utils.ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "identity"), nil
+}
+
+// DefaultRecipientsPath returns the path of the recipients file used by
+// default
+func DefaultRecipientsPath() (string, error) {
+	dir, err := utils.ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "recipients"), nil
+}
+
+// LoadIdentities reads the identities in the file at path, one per line
+// ignoring blank lines and comments starting with #
+func LoadIdentities(path string) ([]*api.Identity, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var identities []*api.Identity
+	for n, line := range lines(b) {
+		if line == "" {
+			continue
+		}
+		id, err := api.ParseIdentity(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, n+1, err)
+		}
+		identities = append(identities, id)
+	}
+	if len(identities) == 0 {
+		return nil, fmt.Errorf("No identities found in %s", path)
+	}
+	return identities, nil
+}
+
+// WriteIdentity writes a new identity file at path readable only by the
+// current user, an existing file is only replaced if force is set
+func WriteIdentity(path string, id *api.Identity, force bool) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if force {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	f, err := os.OpenFile(path, flags, 0600)
+	if errors.Is(err, os.ErrExist) {
+		return fmt.Errorf("Identity file already exists: %s", path)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(
+		f,
+		"# created: %s\n# public key: %s\n%s\n",
+		time.Now().Format(time.RFC3339),
+		id.Recipient(),
+		id,
+	)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// LoadRecipients reads the named recipients in the file at path, each line
+// holds a name and public key separated by whitespace, a missing file holds
+// no recipients
+func LoadRecipients(path string) ([]Entry, error) {
+	b, err := ioutil.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []Entry
+	for n, line := range lines(b) {
+		if line == "" {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: expected a name and public key", path, n+1)
+		}
+		r, err := api.ParseRecipient(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, n+1, err)
+		}
+		entries = append(entries, Entry{Name: fields[0], Recipient: r})
+	}
+	return entries, nil
+}
+
+// recipientName matches the names recipients can be saved under, names
+// can't hold whitespace or # as they would break the recipients file
+var recipientName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._@+-]*$`)
+
+// CheckName checks a recipient can be saved under name
+func CheckName(name string) error {
+	if !recipientName.MatchString(name) {
+		return fmt.Errorf(
+			"Invalid recipient name %q, use letters, digits, '.', '_', '@', '+' and '-'",
+			name,
+		)
+	}
+	return nil
+}
+
+// SaveRecipients replaces the recipients file at path with the entries given
+func SaveRecipients(path string, entries []Entry) error {
+	var buf bytes.Buffer
+	buf.WriteString("# name public-key\n")
+	for _, e := range entries {
+		if err := CheckName(e.Name); err != nil {
+			return err
+		}
+		fmt.Fprintf(&buf, "%s %s\n", e.Name, e.Recipient)
+	}
+
+	return utils.WriteFileAtomic(path, buf.Bytes(), 0644)
+}
+
+// Resolve returns the recipient with the name given or parses it as a public
+// key if no recipient has that name
+func Resolve(entries []Entry, nameOrKey string) (*api.Recipient, error) {
+	for _, e := range entries {
+		if e.Name == nameOrKey {
+			return e.Recipient, nil
+		}
+	}
+	if r, err := api.ParseRecipient(nameOrKey); err == nil {
+		return r, nil
+	}
+	return nil, fmt.Errorf("Unknown recipient: %s", nameOrKey)
+}
+
+// lines splits a file into lines with comments and surrounding whitespace
+// removed
+func lines(b []byte) []string {
+	var out []string
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		out = append(out, strings.TrimSpace(line))
+	}
+	return out
+}
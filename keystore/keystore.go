@@ -0,0 +1,350 @@
+/*
+Copyright © 2022 Harry Law <hrryslw@pm.me>
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+package keystore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/h5law/paste-cli/api"
+	"github.com/h5law/paste-cli/utils"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Version of the keystore file format
+const version = 1
+
+// keySuffix marks the names of the entries holding the key a paste was
+// encrypted with rather than its access key
+const keySuffix = "#key"
+
+// ErrWrongPassphrase is returned opening a keystore with the wrong passphrase
+var ErrWrongPassphrase = errors.New("wrong keystore passphrase")
+
+// file is the JSON document stored on disk, data holds the access keys
+// encrypted with AES-256-GCM under a key derived from the passphrase
+type file struct {
+	Version int              `json:"version"`
+	KDF     string           `json:"kdf"`
+	Params  api.ScryptParams `json:"params"`
+	Salt    []byte           `json:"salt"`
+	Nonce   []byte           `json:"nonce"`
+	Data    []byte           `json:"data"`
+}
+
+// Keystore holds access keys encrypted on disk under a master passphrase,
+// every change locks the file so several processes can use it at once
+type Keystore struct {
+	path   string
+	key    []byte
+	salt   []byte
+	params api.ScryptParams
+}
+
+// DefaultPath returns the path of the keystore file used by default
+func DefaultPath() (string, error) {
+	dir, err := utils.DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "keystore.json"), nil
+}
+
+// Exists checks if a keystore has been created at path
+func Exists(path string) (bool, error) {
+	return utils.FileExists(path)
+}
+
+// Create creates an empty keystore at path protected by passphrase
+func Create(path string, passphrase []byte) (*Keystore, error) {
+	if len(passphrase) == 0 {
+		return nil, errors.New("Passphrase must not be empty")
+	}
+	unlock, err := utils.LockFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	if exists, err := Exists(path); err != nil || exists {
+		if err == nil {
+			err = fmt.Errorf("Keystore already exists: %s", path)
+		}
+		return nil, err
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	key, err := deriveKey(passphrase, salt, api.DefaultScryptParams)
+	if err != nil {
+		return nil, err
+	}
+	k := &Keystore{
+		path:   path,
+		key:    key,
+		salt:   salt,
+		params: api.DefaultScryptParams,
+	}
+	if err := k.write(map[string]string{}); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Open opens the keystore at path with its passphrase
+func Open(path string, passphrase []byte) (*Keystore, error) {
+	f, err := readFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := deriveKey(passphrase, f.Salt, f.Params)
+	if err != nil {
+		return nil, err
+	}
+	return OpenWithKey(path, key)
+}
+
+// OpenWithKey opens the keystore at path with the key derived from its
+// passphrase, as returned by Key
+func OpenWithKey(path string, key []byte) (*Keystore, error) {
+	f, err := readFile(path)
+	if err != nil {
+		return nil, err
+	}
+	k := &Keystore{path: path, key: key, salt: f.Salt, params: f.Params}
+	if _, err := k.decrypt(f); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Salt returns the salt the key of the keystore was derived with, it changes
+// whenever the keystore is recreated
+func Salt(path string) ([]byte, error) {
+	f, err := readFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return f.Salt, nil
+}
+
+// Key returns the key derived from the passphrase so it can be cached
+func (k *Keystore) Key() []byte {
+	return k.key
+}
+
+// Get returns the access key stored for the paste with the uuid given on
+// server
+func (k *Keystore) Get(server, uuid string) (string, bool, error) {
+	keys, err := k.read()
+	if err != nil {
+		return "", false, err
+	}
+	accessKey, ok := keys[entryName(server, uuid)]
+	return accessKey, ok, nil
+}
+
+// GetKey returns the key the paste with the uuid given on server was
+// encrypted with, encoded as in the fragment of its url
+func (k *Keystore) GetKey(server, uuid string) (string, bool, error) {
+	keys, err := k.read()
+	if err != nil {
+		return "", false, err
+	}
+	key, ok := keys[entryName(server, uuid)+keySuffix]
+	return key, ok, nil
+}
+
+// Set stores the access key for the paste with the uuid given on server
+func (k *Keystore) Set(server, uuid, accessKey string) error {
+	return k.modify(func(keys map[string]string) {
+		keys[entryName(server, uuid)] = accessKey
+	})
+}
+
+// SetKey stores the key the paste with the uuid given on server was
+// encrypted with, encoded as in the fragment of its url
+func (k *Keystore) SetKey(server, uuid, key string) error {
+	return k.modify(func(keys map[string]string) {
+		keys[entryName(server, uuid)+keySuffix] = key
+	})
+}
+
+// Delete removes the access key and encryption key for the paste with the
+// uuid given on server
+func (k *Keystore) Delete(server, uuid string) error {
+	return k.modify(func(keys map[string]string) {
+		delete(keys, entryName(server, uuid))
+		delete(keys, entryName(server, uuid)+keySuffix)
+	})
+}
+
+// Entries returns all access keys stored keyed by server and uuid separated
+// by a space
+func (k *Keystore) Entries() (map[string]string, error) {
+	keys, err := k.read()
+	if err != nil {
+		return nil, err
+	}
+	for name := range keys {
+		if strings.HasSuffix(name, keySuffix) {
+			delete(keys, name)
+		}
+	}
+	return keys, nil
+}
+
+// read returns every entry stored, access keys and encryption keys
+func (k *Keystore) read() (map[string]string, error) {
+	unlock, err := utils.LockFile(k.path)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	f, err := readFile(k.path)
+	if err != nil {
+		return nil, err
+	}
+	return k.decrypt(f)
+}
+
+// modify applies fn to the stored access keys while holding the lock on the
+// keystore
+func (k *Keystore) modify(fn func(map[string]string)) error {
+	unlock, err := utils.LockFile(k.path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	f, err := readFile(k.path)
+	if err != nil {
+		return err
+	}
+	keys, err := k.decrypt(f)
+	if err != nil {
+		return err
+	}
+	fn(keys)
+	return k.write(keys)
+}
+
+// decrypt returns the access keys stored in f
+func (k *Keystore) decrypt(f *file) (map[string]string, error) {
+	gcm, err := newGCM(k.key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := gcm.Open(nil, f.Nonce, f.Data, nil)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	keys := make(map[string]string)
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// write encrypts the access keys under a new nonce and replaces the keystore
+// file, the caller must hold the lock
+func (k *Keystore) write(keys map[string]string) error {
+	data, err := json.Marshal(keys)
+	if err != nil {
+		return err
+	}
+	gcm, err := newGCM(k.key)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(file{
+		Version: version,
+		KDF:     "scrypt",
+		Params:  k.params,
+		Salt:    k.salt,
+		Nonce:   nonce,
+		Data:    gcm.Seal(nil, nonce, data, nil),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(k.path, append(b, '\n'), 0600)
+}
+
+// readFile reads and checks the version of the keystore file at path
+func readFile(path string) (*file, error) {
+	b, err := ioutil.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("No keystore found at %s, create one with keystore init", path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var f file
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("Invalid keystore %s: %w", path, err)
+	}
+	if f.Version != version || f.KDF != "scrypt" {
+		return nil, fmt.Errorf("Unsupported keystore version %d in %s", f.Version, path)
+	}
+	return &f, nil
+}
+
+// deriveKey derives the key of a keystore from its passphrase
+func deriveKey(passphrase, salt []byte, params api.ScryptParams) ([]byte, error) {
+	return scrypt.Key(passphrase, salt, params.N, params.R, params.P, api.KeySize)
+}
+
+// newGCM creates an AES-256-GCM cipher from key
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// entryName returns the name access keys are stored under
+func entryName(server, uuid string) string {
+	return server + " " + uuid
+}
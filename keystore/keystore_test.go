@@ -0,0 +1,220 @@
+/*
+Copyright © 2022 Harry Law <hrryslw@pm.me>
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+package keystore
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/h5law/paste-cli/api"
+)
+
+// newKeystore creates a keystore in a temporary directory with cheap scrypt
+// parameters
+func newKeystore(t *testing.T, passphrase string) (*Keystore, string) {
+	t.Helper()
+	params := api.DefaultScryptParams
+	api.DefaultScryptParams = api.ScryptParams{N: 1 << 10, R: 8, P: 1}
+	t.Cleanup(func() { api.DefaultScryptParams = params })
+
+	path := filepath.Join(t.TempDir(), "keystore.json")
+	k, err := Create(path, []byte(passphrase))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k, path
+}
+
+func TestKeystoreOpen(t *testing.T) {
+	_, path := newKeystore(t, "correct horse")
+	tests := []struct {
+		name       string
+		passphrase string
+		want       error
+	}{
+		{"right passphrase", "correct horse", nil},
+		{"wrong passphrase", "battery staple", ErrWrongPassphrase},
+		{"empty passphrase", "", ErrWrongPassphrase},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Open(path, []byte(tt.passphrase))
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Open error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+	if _, err := Open(filepath.Join(t.TempDir(), "missing.json"), []byte("x")); err == nil {
+		t.Error("Open of a missing keystore succeeded")
+	}
+}
+
+func TestKeystoreCreate(t *testing.T) {
+	_, path := newKeystore(t, "pass")
+	if _, err := Create(path, []byte("pass")); err == nil {
+		t.Error("Create over an existing keystore succeeded")
+	}
+	if _, err := Create(filepath.Join(t.TempDir(), "k.json"), nil); err == nil {
+		t.Error("Create with an empty passphrase succeeded")
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm&0077 != 0 {
+		t.Errorf("keystore mode = %#o, want it private", perm)
+	}
+}
+
+func TestKeystoreModify(t *testing.T) {
+	k, path := newKeystore(t, "pass")
+	const server = "https://pastes.ch"
+	if err := k.Set(server, "a", "access-a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := k.Set(server, "b", "access-b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := k.SetKey(server, "a", "key-a"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Changes are visible when opened again
+	k, err := Open(path, []byte("pass"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		get  func(server, uuid string) (string, bool, error)
+		uuid string
+		want string
+		ok   bool
+	}{
+		{k.Get, "a", "access-a", true},
+		{k.Get, "b", "access-b", true},
+		{k.Get, "c", "", false},
+		{k.GetKey, "a", "key-a", true},
+		{k.GetKey, "b", "", false},
+	}
+	for _, tt := range tests {
+		got, ok, err := tt.get(server, tt.uuid)
+		if err != nil || got != tt.want || ok != tt.ok {
+			t.Errorf("get %s = %q, %t, %v, want %q, %t", tt.uuid, got, ok, err, tt.want, tt.ok)
+		}
+	}
+
+	// Encryption keys aren't listed as access keys
+	entries, err := k.Entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[server+" a"] != "access-a" {
+		t.Errorf("Entries = %v", entries)
+	}
+
+	// Deleting removes both keys of a paste
+	if err := k.Delete(server, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := k.Get(server, "a"); ok {
+		t.Error("access key kept after Delete")
+	}
+	if _, ok, _ := k.GetKey(server, "a"); ok {
+		t.Error("encryption key kept after Delete")
+	}
+	if _, ok, _ := k.Get(server, "b"); !ok {
+		t.Error("other access key removed by Delete")
+	}
+
+	// A keystore opened with the wrong key can't change it
+	wrong := &Keystore{path: path, key: make([]byte, api.KeySize), salt: k.salt, params: k.params}
+	if err := wrong.Set(server, "c", "x"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("Set with the wrong key error = %v, want %v", err, ErrWrongPassphrase)
+	}
+	if _, ok, _ := k.Get(server, "b"); !ok {
+		t.Error("keystore changed by Set with the wrong key")
+	}
+}
+
+func TestKeystoreOpenWithKey(t *testing.T) {
+	k, path := newKeystore(t, "pass")
+	if _, err := OpenWithKey(path, k.Key()); err != nil {
+		t.Errorf("OpenWithKey with its key: %s", err)
+	}
+	if _, err := OpenWithKey(path, make([]byte, api.KeySize)); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("OpenWithKey with the wrong key error = %v, want %v", err, ErrWrongPassphrase)
+	}
+}
+
+func TestSession(t *testing.T) {
+	k, path := newKeystore(t, "pass")
+	dir := filepath.Join(t.TempDir(), "session")
+	session := filepath.Join(dir, "keystore-session")
+
+	if err := SaveSession(session, k, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := LoadSession(session, path); !ok {
+		t.Fatal("LoadSession of a saved session failed")
+	}
+	if err := ExpireSession(session); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(session); err != nil {
+		t.Errorf("ExpireSession removed a session which hasn't expired: %v", err)
+	}
+
+	// An expired session is removed
+	if err := SaveSession(session, k, -time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if err := ExpireSession(session); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(session); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("ExpireSession kept an expired session: %v", err)
+	}
+	if _, ok := LoadSession(session, path); ok {
+		t.Error("LoadSession of a removed session succeeded")
+	}
+
+	// A session isn't used for another keystore
+	if err := SaveSession(session, k, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	_, other := newKeystore(t, "pass")
+	if _, ok := LoadSession(session, other); ok {
+		t.Error("LoadSession for another keystore succeeded")
+	}
+}
@@ -0,0 +1,144 @@
+/*
+Copyright © 2022 Harry Law <hrryslw@pm.me>
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+package keystore
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/h5law/paste-cli/utils"
+)
+
+// session caches the key of an unlocked keystore until it expires, like an
+// agent it saves entering the passphrase for every command. The key is kept
+// in plaintext in the session file until ExpireSession removes it, or the
+// next command finds it expired.
+type session struct {
+	Salt      []byte    `json:"salt"`
+	Key       []byte    `json:"key"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// SessionPath returns the path of the session file, kept in
+// $XDG_RUNTIME_DIR which is cleared on logout or a private temporary
+// directory otherwise
+func SessionPath() (string, error) {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "paste", "keystore-session"), nil
+	}
+	dir := filepath.Join(os.TempDir(), fmt.Sprintf("paste-%d", os.Getuid()))
+	return filepath.Join(dir, "keystore-session"), nil
+}
+
+// SaveSession caches the key of the keystore for ttl
+func SaveSession(path string, k *Keystore, ttl time.Duration) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	if err := checkPrivateDir(filepath.Dir(path)); err != nil {
+		return err
+	}
+	b, err := json.Marshal(session{
+		Salt:      k.salt,
+		Key:       k.key,
+		ExpiresAt: time.Now().Add(ttl),
+	})
+	if err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(path, b, 0600)
+}
+
+// LoadSession returns the cached key of the keystore at keystorePath if it
+// hasn't expired and still matches the keystore
+func LoadSession(path, keystorePath string) (*Keystore, bool) {
+	if err := checkPrivateDir(filepath.Dir(path)); err != nil {
+		return nil, false
+	}
+	s, err := readSession(path)
+	if err != nil {
+		return nil, false
+	}
+	if time.Now().After(s.ExpiresAt) {
+		ClearSession(path)
+		return nil, false
+	}
+	salt, err := Salt(keystorePath)
+	if err != nil || !bytes.Equal(salt, s.Salt) {
+		return nil, false
+	}
+	k, err := OpenWithKey(keystorePath, s.Key)
+	if err != nil {
+		return nil, false
+	}
+	return k, true
+}
+
+// ExpireSession removes the cached key once it has expired, a session which
+// can't be read is removed too as it can't be trusted to expire
+func ExpireSession(path string) error {
+	s, err := readSession(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err == nil && time.Now().Before(s.ExpiresAt) {
+		return nil
+	}
+	return ClearSession(path)
+}
+
+// readSession reads the session file at path
+func readSession(path string) (session, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return session{}, err
+	}
+	var s session
+	if err := json.Unmarshal(b, &s); err != nil {
+		return session{}, err
+	}
+	return s, nil
+}
+
+// ClearSession removes any cached key locking the keystore again
+func ClearSession(path string) error {
+	err := os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
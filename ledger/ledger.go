@@ -0,0 +1,230 @@
+/*
+Copyright © 2022 Harry Law <hrryslw@pm.me>
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+package ledger
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/h5law/paste-cli/utils"
+)
+
+// Entry records a paste created with the paste command
+type Entry struct {
+	UUID      string `json:"uuid"`
+	Server    string `json:"server"`
+	AccessKey string `json:"accessKey,omitempty"`
+	// Encrypted is set for pastes encrypted under a key, Key holds it
+	// encoded as in the fragment of its url unless it is in the keystore
+	Encrypted bool      `json:"encrypted,omitempty"`
+	Key       string    `json:"key,omitempty"`
+	FileType  string    `json:"filetype"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	Source    string    `json:"source,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	// Name is an alias for the paste, unique within the ledger
+	Name string `json:"name,omitempty"`
+}
+
+// Ledger is the local record of created pastes kept in a JSON file, every
+// access locks the file so several processes can use it at once
+type Ledger struct {
+	path string
+}
+
+// DefaultPath returns the path of the ledger file used by default
+func DefaultPath() (string, error) {
+	dir, err := utils.DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "ledger.json"), nil
+}
+
+// Open returns the ledger stored at path, the file is created on first write
+func Open(path string) *Ledger {
+	return &Ledger{path: path}
+}
+
+// Path returns the path of the ledger file
+func (l *Ledger) Path() string {
+	return l.path
+}
+
+// Add records a new paste, a name given to it is taken from any paste that
+// already has it
+func (l *Ledger) Add(e Entry) error {
+	return l.Modify(func(entries []Entry) []Entry {
+		clearName(entries, e.Name)
+		return append(entries, e)
+	})
+}
+
+// SetName names the paste with the uuid given on server taking the name from
+// any other paste that has it, false is returned if the paste isn't recorded
+func (l *Ledger) SetName(server, uuid, name string) (bool, error) {
+	found := false
+	err := l.Modify(func(entries []Entry) []Entry {
+		for i := range entries {
+			if entries[i].Server == server && entries[i].UUID == uuid {
+				found = true
+			}
+		}
+		if found {
+			clearName(entries, name)
+			for i := range entries {
+				if entries[i].Server == server && entries[i].UUID == uuid {
+					entries[i].Name = name
+				}
+			}
+		}
+		return entries
+	})
+	return found, err
+}
+
+// RemoveName removes a name from the paste that has it, false is returned if
+// no paste has the name
+func (l *Ledger) RemoveName(name string) (bool, error) {
+	found := false
+	err := l.Modify(func(entries []Entry) []Entry {
+		found = clearName(entries, name)
+		return entries
+	})
+	return found, err
+}
+
+// clearName removes a name from the entries that have it and reports
+// whether any did
+func clearName(entries []Entry, name string) bool {
+	if name == "" {
+		return false
+	}
+	cleared := false
+	for i := range entries {
+		if entries[i].Name == name {
+			entries[i].Name = ""
+			cleared = true
+		}
+	}
+	return cleared
+}
+
+// Entries returns all recorded pastes in the order they were created
+func (l *Ledger) Entries() ([]Entry, error) {
+	unlock, err := utils.LockFile(l.path)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return l.read()
+}
+
+// Find returns the entry for the paste with the uuid given on server
+func (l *Ledger) Find(server, uuid string) (Entry, bool, error) {
+	entries, err := l.Entries()
+	if err != nil {
+		return Entry{}, false, err
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].Server == server && entries[i].UUID == uuid {
+			return entries[i], true, nil
+		}
+	}
+	return Entry{}, false, nil
+}
+
+// Named returns the entry for the paste with the name given
+func (l *Ledger) Named(name string) (Entry, bool, error) {
+	entries, err := l.Entries()
+	if err != nil {
+		return Entry{}, false, err
+	}
+	for _, e := range entries {
+		if e.Name == name {
+			return e, true, nil
+		}
+	}
+	return Entry{}, false, nil
+}
+
+// Recent returns the entry for the nth most recently created paste counting
+// from 0 for the last one
+func (l *Ledger) Recent(n int) (Entry, bool, error) {
+	entries, err := l.Entries()
+	if err != nil {
+		return Entry{}, false, err
+	}
+	if n < 0 || n >= len(entries) {
+		return Entry{}, false, nil
+	}
+	return entries[len(entries)-1-n], true, nil
+}
+
+// Modify replaces the recorded pastes with those returned by fn while
+// holding the lock on the ledger
+func (l *Ledger) Modify(fn func([]Entry) []Entry) error {
+	unlock, err := utils.LockFile(l.path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	entries, err := l.read()
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(fn(entries), "", "  ")
+	if err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(l.path, append(b, '\n'), 0600)
+}
+
+// read loads the entries in the ledger file, a missing file has no entries
+func (l *Ledger) read() ([]Entry, error) {
+	b, err := ioutil.ReadFile(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []Entry
+	if err := json.Unmarshal(b, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
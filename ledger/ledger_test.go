@@ -0,0 +1,128 @@
+/*
+Copyright © 2022 Harry Law <hrryslw@pm.me>
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+package ledger
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+const server = "https://pastes.ch"
+
+func TestLedger(t *testing.T) {
+	l := Open(filepath.Join(t.TempDir(), "paste", "ledger.json"))
+
+	// A missing ledger holds no pastes
+	entries, err := l.Entries()
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("Entries of a new ledger = %v, %v", entries, err)
+	}
+
+	for _, e := range []Entry{
+		{UUID: "a", Server: server, Name: "deploy"},
+		{UUID: "b", Server: server},
+		{UUID: "c", Server: server, Name: "deploy", Encrypted: true, Key: "key-c"},
+	} {
+		if err := l.Add(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		find  func() (Entry, bool, error)
+		uuid  string
+		found bool
+	}{
+		{"last", func() (Entry, bool, error) { return l.Recent(0) }, "c", true},
+		{"~2", func() (Entry, bool, error) { return l.Recent(2) }, "a", true},
+		{"~3", func() (Entry, bool, error) { return l.Recent(3) }, "", false},
+		{"~-1", func() (Entry, bool, error) { return l.Recent(-1) }, "", false},
+		{"name moved", func() (Entry, bool, error) { return l.Named("deploy") }, "c", true},
+		{"unknown name", func() (Entry, bool, error) { return l.Named("other") }, "", false},
+		{"find", func() (Entry, bool, error) { return l.Find(server, "b") }, "b", true},
+		{"other server", func() (Entry, bool, error) { return l.Find("https://other", "b") }, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, found, err := tt.find()
+			if err != nil || found != tt.found || e.UUID != tt.uuid {
+				t.Errorf("got %q, %t, %v, want %q, %t", e.UUID, found, err, tt.uuid, tt.found)
+			}
+		})
+	}
+
+	e, _, _ := l.Find(server, "c")
+	if !e.Encrypted || e.Key != "key-c" {
+		t.Errorf("key of c = %t %q, want it kept", e.Encrypted, e.Key)
+	}
+
+	if found, err := l.SetName(server, "b", "deploy"); err != nil || !found {
+		t.Fatalf("SetName = %t, %v", found, err)
+	}
+	if e, _, _ := l.Named("deploy"); e.UUID != "b" {
+		t.Errorf("deploy names %q after SetName, want b", e.UUID)
+	}
+	if found, _ := l.SetName(server, "missing", "x"); found {
+		t.Error("SetName of a paste not in the ledger succeeded")
+	}
+	if found, err := l.RemoveName("deploy"); err != nil || !found {
+		t.Fatalf("RemoveName = %t, %v", found, err)
+	}
+	if _, found, _ := l.Named("deploy"); found {
+		t.Error("name kept after RemoveName")
+	}
+}
+
+func TestLedgerConcurrentAdd(t *testing.T) {
+	l := Open(filepath.Join(t.TempDir(), "ledger.json"))
+	const n = 20
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := Open(l.Path()).Add(Entry{UUID: fmt.Sprint(i), Server: server}); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	entries, err := l.Entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != n {
+		t.Errorf("ledger holds %d entries after %d concurrent adds", len(entries), n)
+	}
+}
@@ -0,0 +1,85 @@
+/*
+Copyright © 2022 Harry Law <hrryslw@pm.me>
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+package redact
+
+import "regexp"
+
+// Placeholder replaces the secrets found in content
+const Placeholder = "[REDACTED]"
+
+// secretPatterns match secrets commonly found in logs and config files, only
+// the group named secret is replaced in patterns that have one so the key or
+// scheme the secret belongs to is kept
+var secretPatterns = []*regexp.Regexp{
+	// Private keys in PEM armor
+	regexp.MustCompile(`(?s)-----BEGIN [A-Z ]*PRIVATE KEY-----.*?-----END [A-Z ]*PRIVATE KEY-----`),
+	// AWS access key IDs
+	regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`),
+	// GitHub tokens
+	regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`),
+	// Slack tokens
+	regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}\b`),
+	// JSON web tokens
+	regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}\b`),
+	// Bearer and basic credentials in Authorization headers
+	regexp.MustCompile(`(?i)\b(?:bearer|basic)\s+(?P<secret>[A-Za-z0-9._~+/=-]{8,})`),
+	// Passwords in URLs
+	regexp.MustCompile(`\b[a-zA-Z][a-zA-Z0-9+.-]*://[^/\s:@]+:(?P<secret>[^/\s@]+)@`),
+	// Values assigned to keys named like secrets
+	regexp.MustCompile(
+		`(?i)\b[a-z0-9_.-]*(?:password|passwd|secret|token|api[_-]?key|access[_-]?key)[a-z0-9_.-]*["']?\s*[:=]\s*["']?(?P<secret>[^\s"',;]+)`,
+	),
+}
+
+// Content returns content with the secrets found replaced by Placeholder and
+// the number of secrets replaced
+func Content(content []byte) ([]byte, int) {
+	count := 0
+	for _, re := range secretPatterns {
+		group := re.SubexpIndex("secret")
+		content = re.ReplaceAllFunc(content, func(match []byte) []byte {
+			if group < 0 {
+				count++
+				return []byte(Placeholder)
+			}
+			loc := re.FindSubmatchIndex(match)
+			// Leave secrets already replaced by an earlier pattern
+			if string(match[loc[2*group]:loc[2*group+1]]) == Placeholder {
+				return match
+			}
+			count++
+			out := append([]byte{}, match[:loc[2*group]]...)
+			out = append(out, Placeholder...)
+			return append(out, match[loc[2*group+1]:]...)
+		})
+	}
+	return content, count
+}
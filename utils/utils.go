@@ -30,7 +30,21 @@ POSSIBILITY OF SUCH DAMAGE.
 */
 package utils
 
-import "os"
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
 
 // Check path given exists
 func FileExists(path string) (bool, error) {
@@ -49,3 +63,251 @@ func IsInputFromPipe() bool {
 	stat, _ := os.Stdin.Stat()
 	return stat.Mode()&os.ModeCharDevice == 0
 }
+
+// Check if output is to a terminal
+func IsOutputToTerminal() bool {
+	stat, err := os.Stdout.Stat()
+	if err != nil {
+		return false
+	}
+	return stat.Mode()&os.ModeCharDevice != 0
+}
+
+// Directory holding the config and keys of the paste command
+func ConfigDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "paste"), nil
+}
+
+// Directory holding the data of the paste command, $XDG_DATA_HOME/paste
+// falling back to ~/.local/share/paste
+func DataDir() (string, error) {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, "paste"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "share", "paste"), nil
+}
+
+// Maximum size in bytes of content read for a paste
+const MaxContentSize = 10 << 20
+
+// Read the content of the file at path or of os.Stdin if path is empty, the
+// content is returned byte for byte and an error is returned if it is larger
+// than MaxContentSize
+func ReadContent(path string) ([]byte, error) {
+	// Set input file depending to either os.Stdin or path given
+	var input io.ReadCloser
+	name := "stdin"
+	if path == "" {
+		input = os.Stdin
+	} else {
+		// Check file exists and open it
+		exists, err := FileExists(path)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, fmt.Errorf("File not found: %s", path)
+		}
+		input, err = os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		name = path
+	}
+	defer input.Close()
+
+	// Read one byte past the limit to detect oversized input
+	content, err := ioutil.ReadAll(io.LimitReader(input, MaxContentSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("Error reading %s: %w", name, err)
+	}
+	if len(content) > MaxContentSize {
+		return nil, fmt.Errorf(
+			"Input too large: %s exceeds %d bytes",
+			name,
+			MaxContentSize,
+		)
+	}
+
+	return content, nil
+}
+
+// stdin is buffered once so that lines read by successive prompts aren't lost
+var stdin = bufio.NewReader(os.Stdin)
+
+// Prompt for a line of input on os.Stdin returning def if the line is empty,
+// the prompt is written to os.Stderr to keep os.Stdout for output
+func Prompt(prompt, def string) (string, error) {
+	if def != "" {
+		prompt = fmt.Sprintf("%s [%s]", prompt, def)
+	}
+	fmt.Fprint(os.Stderr, prompt+": ")
+	line, err := stdin.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	if err == io.EOF {
+		// End the prompt line as no newline was read
+		fmt.Fprintln(os.Stderr)
+	}
+	if line = strings.TrimSpace(line); line == "" {
+		return def, nil
+	}
+	return line, nil
+}
+
+// Prompt for a secret on the terminal without echoing it, the terminal is
+// opened directly so that os.Stdin can still be used for piped input
+func PromptSecret(prompt string) ([]byte, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		// Fall back to os.Stdin where there is no /dev/tty
+		if !term.IsTerminal(int(os.Stdin.Fd())) {
+			return nil, errors.New("No terminal available to prompt on")
+		}
+		fmt.Fprint(os.Stderr, prompt)
+		secret, err := term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(os.Stderr)
+		return secret, err
+	}
+	defer tty.Close()
+
+	fmt.Fprint(tty, prompt)
+	secret, err := term.ReadPassword(int(tty.Fd()))
+	fmt.Fprintln(tty)
+	return secret, err
+}
+
+// lockTimeout is how long to wait for a lock held by another process
+const lockTimeout = 10 * time.Second
+
+// errLocked is returned by tryLock when another process holds the lock
+var errLocked = errors.New("file is locked")
+
+// Lock the file at path for exclusive access between processes with an OS
+// lock on a lock file next to it, the function returned releases the lock.
+// The OS releases the lock if its holder dies so it can never go stale.
+func LockFile(path string) (func(), error) {
+	lock := path + ".lock"
+	if err := os.MkdirAll(filepath.Dir(lock), 0700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(lock, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		err := tryLock(f)
+		if err == nil {
+			// The lock file is left in place as removing it would let
+			// another process lock a new file while this one is locked
+			return func() {
+				unlock(f)
+				f.Close()
+			}, nil
+		}
+		if !errors.Is(err, errLocked) {
+			f.Close()
+			return nil, err
+		}
+		if time.Now().After(deadline) {
+			f.Close()
+			return nil, fmt.Errorf("Timed out waiting for lock: %s", lock)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Write data to the file at path atomically by writing to a temporary file
+// and renaming it over path
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+var durationPart = regexp.MustCompile(`^(\d+(?:\.\d+)?)(ns|us|µs|ms|s|m|h|d|w)`)
+
+// Parse a duration like time.ParseDuration also accepting days (d) and weeks
+// (w) as units, e.g. "2d" or "1w3d12h"
+func ParseDuration(s string) (time.Duration, error) {
+	rest := s
+	var total time.Duration
+	for rest != "" {
+		m := durationPart.FindStringSubmatch(rest)
+		if m == nil {
+			return 0, fmt.Errorf("Invalid duration: %q", s)
+		}
+		rest = rest[len(m[0]):]
+		switch m[2] {
+		case "d", "w":
+			n, err := strconv.ParseFloat(m[1], 64)
+			if err != nil {
+				return 0, fmt.Errorf("Invalid duration: %q", s)
+			}
+			unit := 24 * time.Hour
+			if m[2] == "w" {
+				unit *= 7
+			}
+			total += time.Duration(n * float64(unit))
+		default:
+			d, err := time.ParseDuration(m[0])
+			if err != nil {
+				return 0, fmt.Errorf("Invalid duration: %q", s)
+			}
+			total += d
+		}
+	}
+	if s == "" {
+		return 0, fmt.Errorf("Invalid duration: %q", s)
+	}
+	return total, nil
+}
+
+// Format a duration rounded to its two largest units of days, hours and
+// minutes, e.g. "3d4h" or "12m"
+func FormatDuration(d time.Duration) string {
+	if d < time.Minute {
+		return "<1m"
+	}
+	days := d / (24 * time.Hour)
+	hours := (d % (24 * time.Hour)) / time.Hour
+	minutes := (d % time.Hour) / time.Minute
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd%dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh%dm", hours, minutes)
+	}
+	return fmt.Sprintf("%dm", minutes)
+}